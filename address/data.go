@@ -0,0 +1,175 @@
+package address
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"poiu.de/brief/parser"
+)
+
+// ReadRecords reads a list of Addresses from the given data file.
+//
+// The format of the file is determined by its file extension. Supported
+// are
+//   - .csv: A comma separated file with the field names in the first row.
+//   - .json: An array of objects with the field names as keys and either
+//     strings, numbers or arrays of those as values.
+//
+// Multi-line values are split into separate lines of the corresponding
+// field.
+//
+// The returned Addresses are in the same order as in the data file.
+func ReadRecords(dataFile string) ([]Address, error) {
+	switch strings.ToLower(filepath.Ext(dataFile)) {
+	case ".csv":
+		return ReadFromCSV(dataFile)
+	case ".json":
+		return ReadFromJSON(dataFile)
+	default:
+		return nil, fmt.Errorf("Unsupported data file %s. Only .csv and .json files are supported.", dataFile)
+	}
+}
+
+// ReadFromCSV reads a list of Addresses from the given CSV file.
+//
+// The first row of the CSV file must contain the field names. Each
+// following row is converted into an Address.
+func ReadFromCSV(csvFile string) ([]Address, error) {
+	file, err := os.Open(csvFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening file %s for reading: %w", csvFile, err)
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading CSV file %s: %w", csvFile, err)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("Invalid CSV file %s. Missing header row.", csvFile)
+	}
+
+	header := records[0]
+	addresses := make([]Address, 0, len(records)-1)
+	for _, record := range records[1:] {
+		a := Address{Fields: make(map[string]parser.BrfLines)}
+		for i, v := range record {
+			k := strings.TrimSpace(header[i])
+			if k == "" || strings.TrimSpace(v) == "" {
+				continue
+			}
+			a.Fields[k] = splitLines(v)
+		}
+		addresses = append(addresses, a)
+	}
+
+	return addresses, nil
+}
+
+// ReadFromJSON reads a list of Addresses from the given JSON file.
+//
+// The JSON file must contain an array of objects. Each of those objects is
+// converted into an Address.
+func ReadFromJSON(jsonFile string) ([]Address, error) {
+	content, err := os.ReadFile(jsonFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file %s: %w", jsonFile, err)
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal(content, &records); err != nil {
+		return nil, fmt.Errorf("Invalid JSON file %s: %w", jsonFile, err)
+	}
+
+	addresses := make([]Address, 0, len(records))
+	for i, record := range records {
+		a := Address{Fields: make(map[string]parser.BrfLines)}
+		for k, v := range record {
+			lines, err := jsonValueToLines(v)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid value for %s in record %d of %s: %w", k, i+1, jsonFile, err)
+			}
+			if len(lines) > 0 {
+				a.Fields[k] = lines
+			}
+		}
+		addresses = append(addresses, a)
+	}
+
+	return addresses, nil
+}
+
+// ReadGroup reads all Addresses from the given address file that are
+// members of the given group.
+//
+// An Address is a member of a group if it has a 'group' field with the
+// name of that group. An Address may be a member of multiple groups.
+//
+// The returned Addresses are sorted by their name (or id) in the address
+// file.
+func ReadGroup(addressFile string, group string) ([]Address, error) {
+	addressBook, err := ReadFromFile(addressFile)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+	for id, a := range addressBook {
+		for _, g := range a.Fields["group"] {
+			if g == group {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	sort.Strings(ids)
+
+	addresses := make([]Address, 0, len(ids))
+	for _, id := range ids {
+		addresses = append(addresses, addressBook[id])
+	}
+
+	return addresses, nil
+}
+
+// jsonValueToLines converts a single value of a JSON object into the lines
+// of an Address field.
+func jsonValueToLines(v interface{}) (parser.BrfLines, error) {
+	switch value := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return splitLines(value), nil
+	case float64, bool:
+		return parser.BrfLines{fmt.Sprint(value)}, nil
+	case []interface{}:
+		lines := make(parser.BrfLines, 0, len(value))
+		for _, e := range value {
+			l, err := jsonValueToLines(e)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, l...)
+		}
+		return lines, nil
+	default:
+		return nil, fmt.Errorf("Unsupported value %v", value)
+	}
+}
+
+// splitLines splits the given string into trimmed lines.
+func splitLines(s string) parser.BrfLines {
+	lines := make(parser.BrfLines, 0)
+	for _, l := range strings.Split(s, "\n") {
+		lines = append(lines, strings.TrimSpace(l))
+	}
+	return parser.TrimSurroundingEmptyLines(lines)
+}
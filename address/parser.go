@@ -66,3 +66,24 @@ func ReadFromFile(filepath string) (map[string]Address, error) {
 
 	return senderAddresses, nil
 }
+
+// Value returns the content of the given field of this Address.
+//
+// If the field consists of multiple lines, those are joined with a comma
+// followed by a space. If the field does not exist, an empty string is
+// returned.
+func (a Address) Value(field string) string {
+	return strings.Join(a.Fields[field], ", ")
+}
+
+// PostalLines returns the lines that make up the postal address of this
+// Address.
+//
+// These are the lines of the 'name' field followed by the lines of the
+// 'postal' field.
+func (a Address) PostalLines() parser.BrfLines {
+	lines := make(parser.BrfLines, 0, len(a.Fields["name"])+len(a.Fields["postal"]))
+	lines = append(lines, a.Fields["name"]...)
+	lines = append(lines, a.Fields["postal"]...)
+	return lines
+}
@@ -0,0 +1,189 @@
+/*
+ * Package backend contains the output backends that render a Letter into
+ * an output file, like a PDF file via LaTeX or an HTML file.
+ *
+ * Backends register themselves via Register and can be looked up by name
+ * via New or ForLetter.
+ */
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+	"poiu.de/brief/parser"
+)
+
+// The backend to use if neither the command line nor the letter specify
+// one.
+const DefaultBackend = "latex"
+
+// Backend renders a Letter into an output file (the artifact).
+type Backend interface {
+	// Name returns the name of this Backend as used in the BACKEND section
+	// of a .brf file and the --backend flag.
+	Name() string
+	// Extension returns the file extension of the artifacts of this
+	// Backend (like "pdf").
+	Extension() string
+	// Render renders the given Letter into the given output file.
+	// Rendering is aborted if the given context is cancelled.
+	Render(ctx context.Context, l *letter.Letter, outFile string) error
+	// DependsOn returns the files the artifact of the given Letter depends
+	// on. If any of them is newer than the artifact, it needs to be
+	// rendered again.
+	DependsOn(l *letter.Letter) []string
+}
+
+// Reporter is implemented by Backends that can tell how they rendered an
+// artifact.
+type Reporter interface {
+	// Report returns a short description of how the artifact was rendered
+	// in the last call of Render (like the engine that was used) or an
+	// empty string if there is nothing to report.
+	Report() string
+}
+
+// Validator is implemented by Backends that cannot render every valid
+// Letter.
+type Validator interface {
+	// Validate checks whether the given Letter can be rendered by this
+	// Backend and returns an error describing the problems otherwise.
+	Validate(l *letter.Letter) error
+}
+
+// Factory creates a Backend for the given configuration.
+type Factory func(cfg *config.Config) Backend
+
+// registry contains all registered Backends by their name.
+var registry = make(map[string]Factory)
+
+// Register registers a Backend under the given name.
+//
+// Registering the same name twice panics, as this is a programming error.
+func Register(name string, factory Factory) {
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("Backend %s already registered", name))
+	}
+	registry[name] = factory
+}
+
+// Names returns the names of all registered Backends, sorted by name.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the Backend with the given name.
+//
+// An error is returned if no such Backend is registered.
+func New(name string, cfg *config.Config) (Backend, error) {
+	factory, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown backend %s. Supported backends are: %s", name, strings.Join(Names(), ", "))
+	}
+	return factory(cfg), nil
+}
+
+// ForLetter creates the Backend to use for the given Letter.
+//
+// If name is not empty, that Backend is used. Otherwise the Backend given
+// in the BACKEND section of the letter is used and if that is missing, the
+// DefaultBackend.
+func ForLetter(l *letter.Letter, name string, cfg *config.Config) (Backend, error) {
+	if name == "" {
+		if section, ok := l.Brf.Sections["BACKEND"]; ok {
+			var err error
+			name, err = parser.GetSingleValue(section)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid backend: %s, \n%w", section, err)
+			}
+		}
+	}
+	if name == "" {
+		name = DefaultBackend
+	}
+
+	return New(name, cfg)
+}
+
+// Validate checks whether the given Letter is valid (see letter.Validate)
+// and can be rendered by the given Backend (see Validator).
+func Validate(b Backend, l *letter.Letter) error {
+	err := l.Validate()
+	if err != nil {
+		return err
+	}
+	if v, ok := b.(Validator); ok {
+		return v.Validate(l)
+	}
+	return nil
+}
+
+// IsStale returns true if the given output file needs to be rendered again
+// by the given Backend for the given Letter, because it doesn't exist or is
+// older than one of the files it depends on.
+func IsStale(b Backend, l *letter.Letter, outFile string) bool {
+	if _, err := os.Stat(outFile); err != nil {
+		return true
+	}
+
+	for _, dep := range b.DependsOn(l) {
+		if !IsNewerThan(outFile, dep) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNewerThan check whether file1 is newer than file2.
+//
+// If file2 does not exists, 'true' is returned.
+// If file1 does not exist, but file2 does, 'false' is returned.
+// If both files don't exist, true is returned.
+// Otherwise this returns 'true' if the modification time of file1 is newer
+// than the modification time of file2.
+func IsNewerThan(file1, file2 string) bool {
+	//TODO: We check here only for errors, not specific NotExistError.
+	//      Should we change that? What should happen on other errors?
+	info2, err := os.Stat(file2)
+	if err != nil {
+		return true
+	}
+
+	info1, err := os.Stat(file1)
+	if err != nil {
+		return false
+	}
+
+	mTime1 := info1.ModTime()
+	mTime2 := info2.ModTime()
+
+	return mTime1.After(mTime2)
+}
+
+// letterDependencies returns the files every artifact of the given Letter
+// depends on. These are the .brf file, the sender list, the address book,
+// the signature image and the enclosures.
+func letterDependencies(l *letter.Letter, cfg *config.Config) []string {
+	deps := []string{l.BrfFile, cfg.SenderList}
+	if cfg.AddressBook != "" {
+		deps = append(deps, cfg.AddressBook)
+	}
+	if l.SignatureFile != "" {
+		deps = append(deps, l.SignatureFile)
+	}
+	for _, e := range l.Enclosures() {
+		deps = append(deps, e.File)
+	}
+	return deps
+}
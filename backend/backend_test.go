@@ -0,0 +1,82 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+	"poiu.de/brief/parser"
+)
+
+func TestForLetter(t *testing.T) {
+	cfg := &config.Config{}
+	cases := []struct {
+		name     string
+		section  parser.BrfLines
+		expected string
+		err      bool
+	}{
+		{"", nil, "latex", false},
+		{"", parser.BrfLines{"html"}, "html", false},
+		{"text", parser.BrfLines{"html"}, "text", false},
+		{"", parser.BrfLines{"unknown"}, "", true},
+		{"", parser.BrfLines{"html", "text"}, "", true},
+	}
+
+	for _, c := range cases {
+		l := &letter.Letter{Brf: parser.Brf{Sections: map[string]parser.BrfLines{}}}
+		if c.section != nil {
+			l.Brf.Sections["BACKEND"] = c.section
+		}
+
+		b, err := ForLetter(l, c.name, cfg)
+		if c.err {
+			if err == nil {
+				t.Errorf("ForLetter(%q, %q) succeeded, expected error", c.section, c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ForLetter(%q, %q) failed: %v", c.section, c.name, err)
+			continue
+		}
+		if b.Name() != c.expected {
+			t.Errorf("ForLetter(%q, %q) == %q, expected %q", c.section, c.name, b.Name(), c.expected)
+		}
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	dir := t.TempDir()
+	brfFile := filepath.Join(dir, "a.brf")
+	outFile := filepath.Join(dir, "a.txt")
+	senderList := filepath.Join(dir, "sender-list")
+	cfg := &config.Config{SenderList: senderList}
+	l := &letter.Letter{BrfFile: brfFile, Brf: parser.Brf{Sections: map[string]parser.BrfLines{}}}
+	b := NewText(cfg)
+
+	now := time.Now()
+	touch := func(file string, age time.Duration) {
+		os.WriteFile(file, []byte{}, 0644)
+		os.Chtimes(file, now.Add(-age), now.Add(-age))
+	}
+
+	touch(brfFile, 2*time.Hour)
+	touch(senderList, 2*time.Hour)
+	if !IsStale(b, l, outFile) {
+		t.Errorf("IsStale() == false for missing output file, expected true")
+	}
+
+	touch(outFile, time.Hour)
+	if IsStale(b, l, outFile) {
+		t.Errorf("IsStale() == true for up to date output file, expected false")
+	}
+
+	touch(senderList, 0)
+	if !IsStale(b, l, outFile) {
+		t.Errorf("IsStale() == false for output file older than sender list, expected true")
+	}
+}
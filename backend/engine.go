@@ -0,0 +1,373 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"poiu.de/brief/cmdline"
+	"poiu.de/brief/config"
+	"poiu.de/brief/utils"
+)
+
+// The maximum number of runs of single-pass engines to get stable
+// references.
+const maxEngineRuns = 4
+
+var (
+	// Messages in the log of single-pass engines that request another run
+	rerunMessages = [][]byte{
+		[]byte("Rerun to get"),
+		[]byte("Label(s) may have changed"),
+		[]byte("Please rerun LaTeX"),
+	}
+
+	// An error in the log of an engine, like "! Undefined control sequence."
+	// or (with -file-line-error) "./letter.tex:12: Undefined control
+	// sequence."
+	logError = regexp.MustCompile(`(?m)^(?:! |\S+\.(?:tex|sty|cls|def):[0-9]+: )(.+)$`)
+	// Parts of errors that are caused by the engine itself and may not occur
+	// with another engine, like missing fonts or packages that require a
+	// specific engine
+	engineErrors = []string{
+		"fontspec",
+		"luaotfload",
+		"LuaTeX",
+		"XeTeX",
+		"not set up for use with LaTeX",
+		"Font",
+		"TeX capacity exceeded",
+	}
+)
+
+// engine compiles a TeX file into a PDF file.
+type engine interface {
+	// compile compiles the given TeX file and returns the path of the
+	// generated PDF file.
+	//
+	// The engine is executed in the given working directory. It writes all
+	// auxiliary files (and, if possible, the PDF file) into the given build
+	// directory.
+	compile(ctx context.Context, texFile string, workingDir string, buildDir string) (string, error)
+}
+
+// compilers contains the LaTeX compilers a template may require via an
+// engine directive (see requiredCompilers).
+var compilers = []string{"pdflatex", "lualatex", "xelatex"}
+
+// The default compiler of build tools like latexmk.
+const defaultCompiler = "pdflatex"
+
+// engineFactories contains the adapters for the known engines by the name
+// of their executable. They create an engine for the configured command
+// line of the engine, which uses the given LaTeX compiler if the engine is
+// a build tool like latexmk.
+var engineFactories = map[string]func(command string, compiler string, cfg *config.Config) engine{
+	"pdflatex": newSinglePassEngine,
+	"lualatex": newSinglePassEngine,
+	"xelatex":  newSinglePassEngine,
+	"latexmk": func(command string, compiler string, cfg *config.Config) engine {
+		flag := "-pdf"
+		if compiler != defaultCompiler {
+			flag = "-" + compiler
+		}
+		return &commandEngine{command: command + " " + flag + " -interaction=nonstopmode -halt-on-error -file-line-error -outdir=%o %f", cfg: cfg}
+	},
+	"latexrun": func(command string, compiler string, cfg *config.Config) engine {
+		if compiler != defaultCompiler {
+			command += " --latex-cmd " + compiler
+		}
+		return &commandEngine{command: command + " -O %o -o %o/%b.pdf %f", cfg: cfg}
+	},
+}
+
+// engineName returns the name of the known engine the given command line
+// consists of or an empty string if it is a custom command line.
+func engineName(command string) string {
+	if strings.ContainsAny(strings.TrimSpace(command), " \t%") {
+		return ""
+	}
+	name := strings.TrimSuffix(filepath.Base(command), ".exe")
+	if _, ok := engineFactories[name]; !ok {
+		return ""
+	}
+	return name
+}
+
+// newEngine creates the engine for the given command line.
+//
+// If the command line consists only of the executable of a known engine
+// (like "pdflatex" or "/usr/bin/latexmk"), its adapter is used, which
+// calls the engine with suitable flags. Build tools like latexmk use the
+// given LaTeX compiler. Otherwise the command line is executed as it is
+// (see commandEngine).
+func newEngine(command string, compiler string, cfg *config.Config) engine {
+	if name := engineName(command); name != "" {
+		return engineFactories[name](command, compiler, cfg)
+	}
+	return &commandEngine{command: cmdline.WithFile(command), cfg: cfg, custom: true}
+}
+
+// candidate is an engine to try for compiling a TeX file.
+type candidate struct {
+	// The name of the engine for reports.
+	name   string
+	engine engine
+}
+
+// candidates returns the engines to try for a TeX file that requires one
+// of the given LaTeX compilers, in the order they should be tried.
+//
+// The configured PdfCommand is preferred, followed by the PdfEngines.
+// Compilers are only tried if they are required and build tools use the
+// first required compiler. Custom command lines are always tried, as it is
+// unknown which compiler they use. If no compilers are required, all
+// engines are tried.
+func candidates(cfg *config.Config, required []string) []candidate {
+	compiler := defaultCompiler
+	for _, r := range required {
+		if contains(compilers, r) {
+			compiler = r
+			break
+		}
+	}
+
+	result := []candidate{}
+	seen := make(map[string]bool)
+	for _, command := range append([]string{cfg.PdfCommand}, cfg.PdfEngines...) {
+		if strings.TrimSpace(command) == "" || seen[command] {
+			continue
+		}
+		seen[command] = true
+
+		name := engineName(command)
+		switch {
+		case name == "":
+			name = command
+		case contains(compilers, name):
+			if len(required) > 0 && !contains(required, name) {
+				continue
+			}
+		default:
+			// build tools can only run the known compilers
+			if len(required) > 0 && !contains(required, compiler) {
+				continue
+			}
+			if compiler != defaultCompiler {
+				name += " (" + compiler + ")"
+			}
+		}
+		result = append(result, candidate{name: name, engine: newEngine(command, compiler, cfg)})
+	}
+	return result
+}
+
+// An engine directive in a TeX template, like "% brief: engine=lualatex".
+var engineDirective = regexp.MustCompile(`(?m)^[ \t]*%[ \t]*brief:[ \t]*engine[ \t]*=(.*)$`)
+
+// requiredCompilers returns the engines required by the engine directives
+// of the given TeX file, like "lualatex" for "% brief: engine=lualatex".
+// Multiple engines are separated by commas.
+//
+// An empty list is returned if the TeX file contains no engine directive
+// and therefore may be compiled by any engine.
+func requiredCompilers(texFile string) ([]string, error) {
+	content, err := os.ReadFile(texFile)
+	if err != nil {
+		return nil, err
+	}
+
+	required := []string{}
+	for _, match := range engineDirective.FindAllSubmatch(content, -1) {
+		for _, name := range strings.FieldsFunc(string(match[1]), func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\r'
+		}) {
+			required = append(required, strings.ToLower(name))
+		}
+	}
+	return required, nil
+}
+
+// contains returns true if the given list contains the given string.
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// commandEngine is an engine that executes a command line once.
+//
+// The command line may contain the placeholders of
+// cmdline.FilePlaceholders with %o being the build directory.
+type commandEngine struct {
+	// The command line to execute.
+	command string
+	// The configuration to take the timeout from.
+	cfg *config.Config
+	// Whether the command line was configured by the user. Custom command
+	// lines may not support writing the PDF file into the build directory.
+	custom bool
+}
+
+// compile executes the command line of this commandEngine.
+//
+// The PDF file is expected in the build directory or, for custom command
+// lines, in the working directory.
+func (e *commandEngine) compile(ctx context.Context, texFile string, workingDir string, buildDir string) (string, error) {
+	pdfFile := filepath.Join(buildDir, utils.DeriveFilePath(filepath.Base(texFile), "pdf"))
+	if e.custom {
+		// don't mistake the result of a previous run for the new one
+		os.Remove(pdfFile)
+	}
+
+	err := runEngine(ctx, e.cfg, e.command, texFile, workingDir, buildDir)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(pdfFile); err == nil || !e.custom {
+		return pdfFile, nil
+	}
+	return filepath.Join(workingDir, filepath.Base(pdfFile)), nil
+}
+
+// singlePassEngine is an engine for LaTeX compilers like pdflatex that
+// resolve references only over multiple runs.
+//
+// It runs the compiler in nonstop mode until the references are stable,
+// but at most maxEngineRuns times.
+type singlePassEngine struct {
+	// The command line to execute.
+	command string
+	// The configuration to take the timeout from.
+	cfg *config.Config
+}
+
+// newSinglePassEngine creates a singlePassEngine for the given executable.
+// The executable is the compiler itself, so the given compiler is ignored.
+func newSinglePassEngine(command string, compiler string, cfg *config.Config) engine {
+	return &singlePassEngine{command: command + " -interaction=nonstopmode -halt-on-error -file-line-error -output-directory=%o %f", cfg: cfg}
+}
+
+// compile runs the compiler until the .aux file doesn't change anymore and
+// the log file doesn't request another run.
+func (e *singlePassEngine) compile(ctx context.Context, texFile string, workingDir string, buildDir string) (string, error) {
+	base := filepath.Join(buildDir, filepath.Base(texFile))
+	auxFile := utils.DeriveFilePath(base, "aux")
+	logFile := utils.DeriveFilePath(base, "log")
+
+	auxHash := fileHash(auxFile)
+	for run := 1; ; run++ {
+		err := runEngine(ctx, e.cfg, e.command, texFile, workingDir, buildDir)
+		if err != nil {
+			return "", err
+		}
+
+		newAuxHash := fileHash(auxFile)
+		if run >= maxEngineRuns || (newAuxHash == auxHash && !requestsRerun(logFile)) {
+			break
+		}
+		auxHash = newAuxHash
+	}
+
+	return utils.DeriveFilePath(base, "pdf"), nil
+}
+
+// runEngine executes the given command line of an engine for the given TeX
+// file in the given working directory.
+//
+// The placeholders of cmdline.FilePlaceholders are relative to the working
+// directory if possible. This avoids problems of TeX with whitespace in
+// absolute paths.
+func runEngine(ctx context.Context, cfg *config.Config, command string, texFile string, workingDir string, buildDir string) error {
+	ctx, cancel := cfg.CommandContext(ctx, config.TimeoutPdf)
+	defer cancel()
+
+	placeholders := cmdline.FilePlaceholders(relativePath(workingDir, texFile), relativePath(workingDir, buildDir))
+	stdout := &strings.Builder{}
+	stderr := &strings.Builder{}
+	return cmdline.ExecuteWithPlaceholders(ctx, command, placeholders, workingDir, nil, stdout, stderr)
+}
+
+// relativePath returns the given path relative to the given base directory
+// or, if that isn't possible, the given path itself.
+func relativePath(base string, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// fileHash returns the SHA-1 hash of the content of the given file or an
+// empty string if it cannot be read.
+func fileHash(file string) string {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha1.Sum(content))
+}
+
+// requestsRerun returns true if the given log file of a LaTeX run contains
+// a message requesting another run.
+func requestsRerun(logFile string) bool {
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		return false
+	}
+	for _, msg := range rerunMessages {
+		if bytes.Contains(content, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// documentError returns the first error in the given log file of an engine
+// if it is an error of the TeX document that every engine fails with (like
+// an undefined control sequence or a missing file).
+//
+// An empty string is returned if the log file cannot be read, contains no
+// error or the error may be specific to the engine.
+func documentError(logFile string) string {
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		return ""
+	}
+	match := logError.FindSubmatch(content)
+	if match == nil {
+		return ""
+	}
+	msg := strings.TrimSpace(string(match[1]))
+	for _, e := range engineErrors {
+		if strings.Contains(msg, e) {
+			return ""
+		}
+	}
+	return msg
+}
+
+// BuildDir returns the build directory for artifacts in the given
+// directory.
+//
+// This is the configured BuildDir, resolved against the given directory if
+// it is relative. If no BuildDir is configured, the given directory itself
+// is used.
+func BuildDir(cfg *config.Config, dir string) string {
+	if cfg.BuildDir == "" {
+		return dir
+	}
+	if filepath.IsAbs(cfg.BuildDir) {
+		return cfg.BuildDir
+	}
+	return filepath.Join(dir, cfg.BuildDir)
+}
@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"poiu.de/brief/config"
+)
+
+func TestNewEngine(t *testing.T) {
+	cfg := &config.Config{}
+	cases := []struct {
+		command    string
+		compiler   string
+		singlePass bool
+		expected   string
+	}{
+		{"pdflatex", "pdflatex", true, "pdflatex -interaction=nonstopmode -halt-on-error -file-line-error -output-directory=%o %f"},
+		{"/usr/bin/xelatex", "pdflatex", true, "/usr/bin/xelatex -interaction=nonstopmode -halt-on-error -file-line-error -output-directory=%o %f"},
+		{"latexmk", "pdflatex", false, "latexmk -pdf -interaction=nonstopmode -halt-on-error -file-line-error -outdir=%o %f"},
+		{"latexmk", "lualatex", false, "latexmk -lualatex -interaction=nonstopmode -halt-on-error -file-line-error -outdir=%o %f"},
+		{"latexrun", "pdflatex", false, "latexrun -O %o -o %o/%b.pdf %f"},
+		{"latexrun", "xelatex", false, "latexrun --latex-cmd xelatex -O %o -o %o/%b.pdf %f"},
+		{"pdflatex -shell-escape", "pdflatex", false, "pdflatex -shell-escape %f"},
+		{"tectonic --outdir %o %f", "pdflatex", false, "tectonic --outdir %o %f"},
+	}
+
+	for _, c := range cases {
+		var command string
+		switch e := newEngine(c.command, c.compiler, cfg).(type) {
+		case *singlePassEngine:
+			if !c.singlePass {
+				t.Errorf("newEngine(%q) returned a singlePassEngine", c.command)
+			}
+			command = e.command
+		case *commandEngine:
+			if c.singlePass {
+				t.Errorf("newEngine(%q) returned a commandEngine", c.command)
+			}
+			command = e.command
+		}
+		if command != c.expected {
+			t.Errorf("newEngine(%q) executes %q, expected %q", c.command, command, c.expected)
+		}
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	cfg := &config.Config{
+		PdfCommand: "latexrun",
+		PdfEngines: []string{"latexrun", "lualatex", "xelatex", "pdflatex"},
+	}
+	cases := []struct {
+		pdfCommand string
+		required   []string
+		expected   []string
+	}{
+		{"latexrun", []string{}, []string{"latexrun", "lualatex", "xelatex", "pdflatex"}},
+		{"latexrun", []string{"lualatex"}, []string{"latexrun (lualatex)", "lualatex"}},
+		{"pdflatex", []string{"xelatex", "lualatex"}, []string{"latexrun (xelatex)", "lualatex", "xelatex"}},
+		{"tectonic", []string{"tectonic"}, []string{"tectonic"}},
+		{"pdflatex -shell-escape", []string{"lualatex"}, []string{"pdflatex -shell-escape", "latexrun (lualatex)", "lualatex"}},
+	}
+
+	for _, c := range cases {
+		cfg.PdfCommand = c.pdfCommand
+		names := []string{}
+		for _, e := range candidates(cfg, c.required) {
+			names = append(names, e.name)
+		}
+		if !reflect.DeepEqual(names, c.expected) {
+			t.Errorf("candidates(%q, %q) == %q, expected %q", c.pdfCommand, c.required, names, c.expected)
+		}
+	}
+}
+
+func TestRequiredCompilers(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		tex      string
+		expected []string
+	}{
+		{"\\documentclass{scrlttr2}\n", []string{}},
+		{"% brief: engine=lualatex\n\\documentclass{scrlttr2}\n", []string{"lualatex"}},
+		{"  %brief: engine = XeLaTeX, lualatex\n", []string{"xelatex", "lualatex"}},
+		{"\\usepackage{fontspec} % brief: engine=lualatex\n", []string{}},
+	}
+
+	for i, c := range cases {
+		texFile := filepath.Join(dir, "letter.tex")
+		if err := os.WriteFile(texFile, []byte(c.tex), 0644); err != nil {
+			t.Fatal(err)
+		}
+		required, err := requiredCompilers(texFile)
+		if err != nil {
+			t.Errorf("requiredCompilers(case %d) failed: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(required, c.expected) {
+			t.Errorf("requiredCompilers(case %d) == %q, expected %q", i, required, c.expected)
+		}
+	}
+}
+
+func TestSinglePassEngineReruns(t *testing.T) {
+	dir := t.TempDir()
+	buildDir := filepath.Join(dir, ".brief-build")
+	if err := os.Mkdir(buildDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	texFile := filepath.Join(buildDir, "letter.tex")
+	if err := os.WriteFile(texFile, []byte("tex"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a fake engine whose references are stable after the second run
+	script := filepath.Join(dir, "fake-latex")
+	err := os.WriteFile(script, []byte(`#!/bin/sh
+echo run >> runs
+runs=$(wc -l < runs)
+[ "$runs" -gt 2 ] && runs=2
+echo "$runs" > .brief-build/letter.aux
+echo pdf > .brief-build/letter.pdf
+`), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &singlePassEngine{command: script, cfg: &config.Config{}}
+	pdfFile, err := e.compile(context.Background(), texFile, dir, buildDir)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if pdfFile != filepath.Join(buildDir, "letter.pdf") {
+		t.Errorf("compile returned %s, expected %s", pdfFile, filepath.Join(buildDir, "letter.pdf"))
+	}
+	runs, err := os.ReadFile(filepath.Join(dir, "runs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(runs), "run"); n != 3 {
+		t.Errorf("compile ran engine %d times, expected 3", n)
+	}
+}
+
+func TestRequestsRerun(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		log      string
+		expected bool
+	}{
+		{"Output written on letter.pdf", false},
+		{"LaTeX Warning: Label(s) may have changed. Rerun to get cross-references right.", true},
+		{"Package rerunfilecheck Warning: File `letter.out' has changed.\n(rerunfilecheck) Rerun to get outlines right", true},
+	}
+
+	for i, c := range cases {
+		logFile := filepath.Join(dir, "letter.log")
+		if err := os.WriteFile(logFile, []byte(c.log), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if r := requestsRerun(logFile); r != c.expected {
+			t.Errorf("requestsRerun(case %d) == %v, expected %v", i, r, c.expected)
+		}
+	}
+	if requestsRerun(filepath.Join(dir, "missing.log")) {
+		t.Errorf("requestsRerun(missing.log) == true, expected false")
+	}
+}
+
+func TestDocumentError(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		log      string
+		expected string
+	}{
+		{"Output written on letter.pdf", ""},
+		{"./letter.tex:12: Undefined control sequence.\nl.12 \\foo", "Undefined control sequence."},
+		{"! LaTeX Error: File `missing.sty' not found.", "LaTeX Error: File `missing.sty' not found."},
+		{"! Fatal Package fontspec Error: The fontspec package requires either XeTeX or LuaTeX.", ""},
+		{"./letter.tex:3: Package inputenc Error: Unicode character ❤ (U+2764) not set up for use with LaTeX.", ""},
+		{"! TeX capacity exceeded, sorry [main memory size=5000000].", ""},
+	}
+
+	for i, c := range cases {
+		logFile := filepath.Join(dir, "letter.log")
+		if err := os.WriteFile(logFile, []byte(c.log), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if msg := documentError(logFile); msg != c.expected {
+			t.Errorf("documentError(case %d) == %q, expected %q", i, msg, c.expected)
+		}
+	}
+	if msg := documentError(filepath.Join(dir, "missing.log")); msg != "" {
+		t.Errorf("documentError(missing.log) == %q, expected empty string", msg)
+	}
+}
@@ -0,0 +1,321 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+	"poiu.de/brief/locale"
+	"poiu.de/brief/markup"
+	"poiu.de/brief/parser"
+	"poiu.de/brief/utils"
+)
+
+func init() {
+	Register("html", func(cfg *config.Config) Backend { return NewHtml(cfg) })
+}
+
+// defaultHtmlTemplate is used if no HTML template exists for the template
+// of a letter.
+const defaultHtmlTemplate = `<!DOCTYPE html>
+<html lang="{{.LANGUAGE}}">
+<head>
+<meta charset="utf-8">
+<title>{{.SUBJECT}}</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; line-height: 1.4; }
+.sender { text-align: right; }
+.recipient, .date, .subject, .signature, .enclosures { margin: 1.5em 0; }
+.date { text-align: right; }
+.subject { font-weight: bold; }
+</style>
+</head>
+<body>
+<div class="sender">{{.fromName}}<br>
+{{.fromAddress}}</div>
+<div class="recipient">{{.TO}}</div>
+<div class="date">{{.DATE}}</div>
+<div class="subject">{{.SUBJECT}}</div>
+<div class="content">
+{{.CONTENT}}
+</div>
+{{with .SIGNATURE}}<div class="signature">{{.}}</div>
+{{end}}{{with .ENCLOSURES}}<div class="enclosures">{{.}}</div>
+{{end}}</body>
+</html>
+`
+
+// Html is the Backend that generates self-contained HTML files.
+//
+// It fills the content of a letter into an HTML template. Unlike the
+// Latex backend, all values are escaped for HTML. It utilizes the
+// configured markup converters to convert markup blocks (like markdown or
+// asciidoc) to HTML code.
+type Html struct {
+	cfg *config.Config
+}
+
+// NewHtml creates a new Html backend for the given configuration.
+func NewHtml(cfg *config.Config) *Html {
+	return &Html{cfg: cfg}
+}
+
+// Name returns the name of this Backend.
+func (b *Html) Name() string {
+	return "html"
+}
+
+// Extension returns the file extension of the artifacts of this Backend.
+func (b *Html) Extension() string {
+	return "html"
+}
+
+// DependsOn returns the files the HTML file of the given Letter depends
+// on. These are the files of the letter itself and the HTML template (if
+// one exists).
+func (b *Html) DependsOn(l *letter.Letter) []string {
+	deps := letterDependencies(l, b.cfg)
+	if htmlTemplate := b.templateFile(l); htmlTemplate != "" {
+		deps = append(deps, htmlTemplate)
+	}
+	return deps
+}
+
+// Render generates the HTML file for the given Letter.
+func (b *Html) Render(ctx context.Context, l *letter.Letter, htmlFile string) error {
+	tmpl, err := b.htmlTemplate(l)
+	if err != nil {
+		return err
+	}
+
+	input := make(map[string]interface{})
+	for k, v := range b.contentToHtmlInput(ctx, l) {
+		input[k] = v
+	}
+	for k, v := range l.Sender.Fields {
+		input[k] = htmlLines(v)
+	}
+	for k, v := range languageToHtmlInput(l.Locale) {
+		input[k] = v
+	}
+	// the same date keys as for the tex templates
+	d := l.DateRenderings()
+	input["dateLocale"] = d["date"]
+	input["dateLong"] = d["date.long"]
+	input["dateShort"] = d["date.short"]
+	input["dateISO"] = d["date.iso"]
+	if _, ok := l.Brf.Sections["ENCLOSURES"]; ok {
+		input["ENCLOSURES"] = htmlLines(l.EnclosureLines())
+	}
+
+	input["SIGNATURE"], err = signatureToHtmlInput(l)
+	if err != nil {
+		return err
+	}
+
+	//prepare the target file, which is only replaced once it is complete
+	f, err := utils.CreateAtomic(htmlFile)
+	if err != nil {
+		return fmt.Errorf("Could create output file %s: %w", htmlFile, err)
+	}
+	defer f.Abort()
+
+	//write to target file (using template)
+	w := bufio.NewWriter(f)
+	err = tmpl.Execute(w, input)
+	if err != nil {
+		return fmt.Errorf("Error converting %s: %w", l.BrfFile, err)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return fmt.Errorf("Could not write output file %s: %w", htmlFile, err)
+	}
+
+	return f.Commit()
+}
+
+// templateFile returns the HTML template file for the given Letter.
+//
+// This is the file in the HTML template directory with the same name as
+// the tex template of the letter, but with the extension .html. If no such
+// file exists, an empty string is returned.
+func (b *Html) templateFile(l *letter.Letter) string {
+	texTemplate, err := parser.GetSingleValue(l.Brf.Sections["TEMPLATE"])
+	if err != nil || b.cfg.HtmlTemplateDir == "" {
+		return ""
+	}
+
+	htmlTemplate := filepath.Join(b.cfg.HtmlTemplateDir, utils.DeriveFilePath(texTemplate, "html"))
+	if _, err := os.Stat(htmlTemplate); err != nil {
+		return ""
+	}
+	return htmlTemplate
+}
+
+// htmlTemplate returns the HTML template for the given Letter.
+//
+// If no HTML template file exists for the letter, a built-in default
+// template is used.
+func (b *Html) htmlTemplate(l *letter.Letter) (*template.Template, error) {
+	htmlTemplate := b.templateFile(l)
+	if htmlTemplate == "" {
+		return template.New("default").Parse(defaultHtmlTemplate)
+	}
+
+	tmpl, err := template.ParseFiles(htmlTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading html template %s: %w", htmlTemplate, err)
+	}
+	return tmpl, nil
+}
+
+// contentToHtmlInput converts the content of the sections of a .brf file
+// into HTML suitable to be filled into an HTML template.
+//
+// Unlike for LaTeX all values are escaped here already, since the
+// templates only escape values that are not of type template.HTML.
+// Any markup in the CONTENT section is converted into HTML code. Straight
+// quotes in plain text are replaced with the typographic quotes of the
+// letters language and newlines in sections other than CONTENT are replaced
+// with line breaks.
+func (b *Html) contentToHtmlInput(ctx context.Context, l *letter.Letter) map[string]template.HTML {
+	r := make(map[string]template.HTML)
+	for k, v := range l.Brf.Sections {
+		v = parser.TrimSurroundingEmptyLines(v)
+
+		if k != "CONTENT" {
+			r[k] = htmlLines(strings.Split(l.Locale.Quote(strings.Join(v, "\n")), "\n"))
+		} else {
+			r[k] = b.convertMarkup(ctx, v, l.Locale)
+		}
+	}
+
+	return r
+}
+
+// convertMarkup converts the given lines of the CONTENT section into HTML.
+//
+// Markup blocks are converted via the configured markup converters. Plain
+// text is split into paragraphs at empty lines and gets its straight quotes
+// replaced with the typographic quotes of the given Locale.
+// If markup conversion fails those lines will be included as preformatted
+// text (with the surrounding markup block separators).
+func (b *Html) convertMarkup(ctx context.Context, a []string, loc *locale.Locale) template.HTML {
+	blocks := markup.SplitBlocks(a)
+	converted := make([]string, len(blocks))
+	for i, block := range blocks {
+		if block.MarkupType == "" {
+			converted[i] = string(htmlParagraphs(strings.Split(loc.Quote(strings.Join(block.Lines, "\n")), "\n")))
+			continue
+		}
+
+		mc, err := markup.NewConverter(block.MarkupType, loc.Code, markup.TargetHtml, b.cfg)
+		if err == nil {
+			converted[i], err = mc.Convert(ctx, block.Lines)
+		}
+		if err != nil {
+			log.Println(fmt.Errorf("Cannot convert markup %s. Leaving as is. %w", block.MarkupType, err))
+			converted[i] = "<pre>" + html.EscapeString(strings.Join(block.Raw, "\n")) + "</pre>"
+		}
+	}
+
+	return template.HTML(strings.Join(converted, "\n"))
+}
+
+// languageToHtmlInput returns the language specific values for an HTML
+// template.
+//
+// These are the same as for the tex templates, but without any LaTeX
+// specific escaping.
+func languageToHtmlInput(l *locale.Locale) map[string]string {
+	r := make(map[string]string)
+	r["LANGUAGE"] = l.Code
+	for k, v := range l.Labels {
+		r["label"+strings.ToUpper(k[:1])+k[1:]] = v
+	}
+
+	return r
+}
+
+// signatureToHtmlInput returns an HTML img element that embeds the
+// signature image of the given Letter as data URI. The width of the image
+// can be specified via the 'signatureWidth' field of the sender and
+// defaults to 4cm.
+//
+// PDF signatures cannot be embedded into HTML and are therefore skipped.
+//
+// If the letter has no signature image, an empty string is returned.
+func signatureToHtmlInput(l *letter.Letter) (template.HTML, error) {
+	if l.SignatureFile == "" {
+		return "", nil
+	}
+
+	mimeTypes := map[string]string{
+		".png":  "image/png",
+		".jpg":  "image/jpeg",
+		".jpeg": "image/jpeg",
+		".svg":  "image/svg+xml",
+	}
+	mimeType, ok := mimeTypes[strings.ToLower(filepath.Ext(l.SignatureFile))]
+	if !ok {
+		log.Println(fmt.Errorf("Cannot embed signature image %s into html. Skipping it.", l.SignatureFile))
+		return "", nil
+	}
+
+	data, err := os.ReadFile(l.SignatureFile)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read signature image %s: %w", l.SignatureFile, err)
+	}
+
+	width := l.Sender.Value("signatureWidth")
+	if width == "" {
+		width = "4cm"
+	}
+
+	return template.HTML(fmt.Sprintf(`<img src="data:%s;base64,%s" style="width: %s" alt="">`,
+		mimeType, base64.StdEncoding.EncodeToString(data), html.EscapeString(width))), nil
+}
+
+// htmlLines escapes the given lines for HTML and joins them with line
+// breaks.
+func htmlLines(lines []string) template.HTML {
+	escaped := make([]string, len(lines))
+	for i, line := range lines {
+		escaped[i] = html.EscapeString(line)
+	}
+	return template.HTML(strings.Join(escaped, "<br>\n"))
+}
+
+// htmlParagraphs escapes the given lines for HTML and puts them into
+// paragraphs. Paragraphs are separated by empty lines.
+func htmlParagraphs(lines []string) template.HTML {
+	var b strings.Builder
+	paragraph := make([]string, 0)
+	flush := func() {
+		if len(paragraph) > 0 {
+			b.WriteString("<p>" + string(htmlLines(paragraph)) + "</p>\n")
+			paragraph = paragraph[:0]
+		}
+	}
+
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			flush()
+		} else {
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+
+	return template.HTML(b.String())
+}
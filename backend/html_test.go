@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"context"
+	"encoding/base64"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"poiu.de/brief/address"
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+	"poiu.de/brief/locale"
+	"poiu.de/brief/parser"
+)
+
+func TestHtmlParagraphs(t *testing.T) {
+	cases := []struct {
+		lines    []string
+		expected template.HTML
+	}{
+		{[]string{}, ""},
+		{[]string{"a & b"}, "<p>a &amp; b</p>\n"},
+		{[]string{"a", "<b>", "", "", "c"}, "<p>a<br>\n&lt;b&gt;</p>\n<p>c</p>\n"},
+		{[]string{"", " ", "a", "\t", "b", ""}, "<p>a</p>\n<p>b</p>\n"},
+		{[]string{"\"x\" 'y'"}, "<p>&#34;x&#34; &#39;y&#39;</p>\n"},
+	}
+
+	for _, c := range cases {
+		result := htmlParagraphs(c.lines)
+		if result != c.expected {
+			t.Errorf("htmlParagraphs(%q) == %q, expected %q", c.lines, result, c.expected)
+		}
+	}
+}
+
+func TestHtmlEscapesUserContent(t *testing.T) {
+	en, err := locale.Get("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &letter.Letter{
+		BrfFile: "a.brf",
+		Sender:  address.Address{Fields: map[string]parser.BrfLines{"fromName": {"<script>alert(1)</script>"}}},
+		Locale:  en,
+		Date:    time.Date(2020, 3, 3, 0, 0, 0, 0, time.UTC),
+		Brf: parser.Brf{Sections: map[string]parser.BrfLines{
+			"TO":         {"Tom & Jerry", "<Main St>"},
+			"SUBJECT":    {"<b>bold</b>"},
+			"CONTENT":    {"1 < 2 & 3 > 2"},
+			"ENCLOSURES": {"a.pdf | <img src=x>"},
+		}},
+	}
+
+	htmlFile := filepath.Join(t.TempDir(), "a.html")
+	err = NewHtml(&config.Config{}).Render(context.Background(), l, htmlFile)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	content, err := os.ReadFile(htmlFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, raw := range []string{"<script>", "<b>", "<Main St>", "<img src=x>", "1 < 2"} {
+		if strings.Contains(string(content), raw) {
+			t.Errorf("Rendered html contains unescaped %q", raw)
+		}
+	}
+	for _, escaped := range []string{"&lt;script&gt;alert(1)&lt;/script&gt;", "Tom &amp; Jerry", "&lt;b&gt;bold&lt;/b&gt;", "1 &lt; 2 &amp; 3 &gt; 2", "&lt;img src=x&gt;"} {
+		if !strings.Contains(string(content), escaped) {
+			t.Errorf("Rendered html doesn't contain %q", escaped)
+		}
+	}
+}
+
+func TestSignatureToHtmlInput(t *testing.T) {
+	dir := t.TempDir()
+	png := filepath.Join(dir, "me.PNG")
+	if err := os.WriteFile(png, []byte("png data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data := base64.StdEncoding.EncodeToString([]byte("png data"))
+
+	cases := []struct {
+		signature string
+		width     string
+		expected  template.HTML
+		fails     bool
+	}{
+		{"", "", "", false},
+		{png, "", template.HTML(`<img src="data:image/png;base64,` + data + `" style="width: 4cm" alt="">`), false},
+		{png, `3cm" onload="x`, template.HTML(`<img src="data:image/png;base64,` + data + `" style="width: 3cm&#34; onload=&#34;x" alt="">`), false},
+		{filepath.Join(dir, "me.pdf"), "", "", false},
+		{filepath.Join(dir, "missing.png"), "", "", true},
+	}
+
+	for _, c := range cases {
+		l := &letter.Letter{SignatureFile: c.signature, Sender: address.Address{Fields: map[string]parser.BrfLines{}}}
+		if c.width != "" {
+			l.Sender.Fields["signatureWidth"] = parser.BrfLines{c.width}
+		}
+		result, err := signatureToHtmlInput(l)
+		if (err != nil) != c.fails || result != c.expected {
+			t.Errorf("signatureToHtmlInput() for %q and width %q == %q, %v, expected %q", c.signature, c.width, result, err, c.expected)
+		}
+	}
+}
@@ -0,0 +1,553 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"poiu.de/brief/address"
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+	"poiu.de/brief/locale"
+	"poiu.de/brief/markup"
+	"poiu.de/brief/parser"
+	"poiu.de/brief/utils"
+)
+
+var (
+	// Replacer to replace special characters with their LaTeX equivalents
+	// FIXME: Find a better name, since it is not only about Unicode
+	utfCharReplacer = strings.NewReplacer(
+		` `, `\,`, // thin space
+		`_`, "\\string_", // underscore character
+		`^`, "\\string^", // caret character
+	)
+
+	inlineMarkupReplacer = strings.NewReplacer(
+		`/`, `\/`, // italics
+	)
+
+	// Replacer for file paths in LaTeX commands like \includegraphics.
+	// The special characters are replaced by expandable commands that
+	// produce the plain characters, since the file name gets expanded.
+	texPathReplacer = strings.NewReplacer(
+		`\`, `\csname @backslashchar\endcsname `,
+		`%`, `\csname @percentchar\endcsname `,
+		`#`, `\string#`,
+		`~`, `\string~`,
+		`$`, `\string$`,
+		`&`, `\string&`,
+		`^`, `\string^`,
+		`_`, `\string_`,
+		`{`, `\csname @charlb\endcsname `,
+		`}`, `\csname @charrb\endcsname `,
+	)
+)
+
+// TexMarker starts the first line of all TeX files generated by brief.
+//
+// The header line also contains the hash of the rest of the file to detect
+// manual changes.
+const TexMarker = "% Generated by brief"
+
+// ErrTexModified is returned if a TeX file is not overwritten, because it
+// was modified manually.
+var ErrTexModified = errors.New("File was not generated by brief or modified manually")
+
+// The hash in the header line of generated TeX files.
+var texHeaderHash = regexp.MustCompile(`sha1 ([0-9a-f]{40})`)
+
+func init() {
+	Register("latex", func(cfg *config.Config) Backend { return NewLatex(cfg) })
+}
+
+// Latex is the Backend that generates PDF files via LaTeX.
+//
+// It fills the content of a letter into a TeX template and calls an
+// external application to generate the PDF file from the resulting TeX
+// file. It utilizes the configured markup converters to convert markup
+// blocks (like markdown or asciidoc) to LaTeX code.
+type Latex struct {
+	// Whether WriteTex overwrites TeX files that were not generated by brief
+	// or were modified manually.
+	Force bool
+
+	cfg *config.Config
+	// The report of the last call of Render.
+	report string
+}
+
+// NewLatex creates a new Latex backend for the given configuration.
+func NewLatex(cfg *config.Config) *Latex {
+	return &Latex{cfg: cfg}
+}
+
+// Name returns the name of this Backend.
+func (b *Latex) Name() string {
+	return "latex"
+}
+
+// Extension returns the file extension of the artifacts of this Backend.
+func (b *Latex) Extension() string {
+	return "pdf"
+}
+
+// IsGeneratedTex returns true if the given file is a TeX file generated by
+// brief that was not modified since, that is if it starts with the
+// TexMarker and the hash in that header line matches the rest of the file.
+func IsGeneratedTex(file string) bool {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return false
+	}
+
+	i := bytes.IndexByte(content, '\n')
+	if i < 0 || !bytes.HasPrefix(content, []byte(TexMarker)) {
+		return false
+	}
+	match := texHeaderHash.FindSubmatch(content[:i])
+	return match != nil && string(match[1]) == fmt.Sprintf("%x", sha1.Sum(content[i+1:]))
+}
+
+// Report returns the engine that generated the PDF file in the last call
+// of Render.
+func (b *Latex) Report() string {
+	return b.report
+}
+
+// DependsOn returns the files the PDF file of the given Letter depends on.
+// These are the files of the letter itself and the TeX template.
+func (b *Latex) DependsOn(l *letter.Letter) []string {
+	deps := letterDependencies(l, b.cfg)
+	if texTemplate, err := parser.GetSingleValue(l.Brf.Sections["TEMPLATE"]); err == nil {
+		deps = append(deps, filepath.Join(b.cfg.TexTemplateDir, texTemplate))
+	}
+	return deps
+}
+
+// Render generates the PDF file for the given Letter.
+//
+// The intermediate TeX file and all auxiliary files are written into the
+// build directory (see Config.BuildDir) and only the final PDF file is
+// copied to its destination. The external application is executed in the
+// directory of the PDF file. The enclosures of the letter are appended to
+// the generated PDF file.
+//
+// Known engines (like pdflatex or latexmk) are called with suitable flags
+// and single-pass engines are rerun until all references are resolved.
+// A custom PdfCommand may refer to the TeX file via the placeholders of
+// cmdline.FilePlaceholders (with %o being the build directory). Their
+// values are relative to the directory of the PDF file. If the PdfCommand
+// contains neither %f nor %b, the TeX file is appended to it.
+//
+// If an engine fails, the next compatible engine of the PdfEngines is
+// tried. A template may restrict the compatible engines via a directive
+// like "% brief: engine=lualatex,xelatex".
+func (b *Latex) Render(ctx context.Context, l *letter.Letter, pdfFile string) error {
+	workingDir, buildDir, err := b.prepareBuild(pdfFile)
+	if err != nil {
+		return err
+	}
+
+	// the TeX file in the build directory is no artifact, so it is always
+	// overwritten
+	texFile := filepath.Join(buildDir, utils.DeriveFilePath(filepath.Base(pdfFile), "tex"))
+	err = b.writeTex(ctx, l, texFile, true)
+	if err != nil {
+		return fmt.Errorf("Cannot create tex file for %s: %w", l.BrfFile, err)
+	}
+
+	return b.compile(ctx, l, texFile, pdfFile, workingDir, buildDir)
+}
+
+// RenderTex generates the PDF file for the given Letter from the given
+// existing TeX file (for example one that was edited manually) instead of
+// generating the TeX file from the letter.
+//
+// Apart from that it works like Render. The letter is only used for its
+// enclosures.
+func (b *Latex) RenderTex(ctx context.Context, l *letter.Letter, texFile string, pdfFile string) error {
+	workingDir, buildDir, err := b.prepareBuild(pdfFile)
+	if err != nil {
+		return err
+	}
+
+	absTexFile, err := filepath.Abs(texFile)
+	if err != nil {
+		return fmt.Errorf("Cannot determine path of tex file %s: %w", texFile, err)
+	}
+	if _, err := os.Stat(absTexFile); err != nil {
+		return fmt.Errorf("Cannot read tex file for %s: %w", l.BrfFile, err)
+	}
+
+	return b.compile(ctx, l, absTexFile, pdfFile, workingDir, buildDir)
+}
+
+// prepareBuild checks the configuration and creates the build directory for
+// the given PDF file.
+//
+// The working directory for the engines (the absolute directory of the PDF
+// file) and the build directory are returned.
+func (b *Latex) prepareBuild(pdfFile string) (string, string, error) {
+	if b.cfg.PdfCommand == "" && len(b.cfg.PdfEngines) == 0 {
+		return "", "", fmt.Errorf("No pdf command configured. Cannot produce pdf file.")
+	}
+
+	workingDir, err := filepath.Abs(filepath.Dir(pdfFile))
+	if err != nil {
+		return "", "", fmt.Errorf("Cannot determine directory of pdf file %s: %w", pdfFile, err)
+	}
+	buildDir := BuildDir(b.cfg, workingDir)
+	err = os.MkdirAll(buildDir, 0755)
+	if err != nil {
+		return "", "", fmt.Errorf("Cannot create build directory %s: %w", buildDir, err)
+	}
+	return workingDir, buildDir, nil
+}
+
+// compile compiles the given TeX file into the given PDF file via the
+// first compatible engine that succeeds and appends the enclosures of the
+// given Letter.
+//
+// The next engine is only tried if the failure may be specific to the
+// engine. Errors in the TeX document itself are returned right away (see
+// documentError). If all engines fail, the error of the first engine is
+// returned, since the others are only fallbacks.
+func (b *Latex) compile(ctx context.Context, l *letter.Letter, texFile string, pdfFile string, workingDir string, buildDir string) error {
+	required, err := requiredCompilers(texFile)
+	if err != nil {
+		return fmt.Errorf("Cannot read tex file for %s: %w", l.BrfFile, err)
+	}
+	engines := candidates(b.cfg, required)
+	if len(engines) == 0 {
+		return fmt.Errorf("No configured pdf engine can compile %s, which requires %s.", l.BrfFile, strings.Join(required, " or "))
+	}
+
+	logFile := filepath.Join(buildDir, utils.DeriveFilePath(filepath.Base(texFile), "log"))
+	var generatedFile string
+	var firstErr error
+	failed := []string{}
+	for _, e := range engines {
+		// don't mistake the log of a previous engine for the current one
+		os.Remove(logFile)
+		generatedFile, err = e.engine.compile(ctx, texFile, workingDir, buildDir)
+		if err == nil {
+			b.report = e.name
+			if len(failed) > 0 {
+				b.report += " (after " + strings.Join(failed, ", ") + " failed)"
+			}
+			break
+		}
+		failed = append(failed, e.name)
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil || len(failed) == len(engines) {
+			break
+		}
+		if msg := documentError(logFile); msg != "" {
+			// other engines would fail the same way
+			return fmt.Errorf("Error generating pdf file for %s with %s (%s): %w", l.BrfFile, e.name, strings.TrimSuffix(msg, "."), err)
+		}
+		log.Println(fmt.Errorf("Engine %s failed for %s, trying next engine: %w", e.name, l.BrfFile, err))
+	}
+	if err != nil {
+		if len(failed) > 1 {
+			return fmt.Errorf("Error generating pdf file for %s with %s (%s failed as well): %w", l.BrfFile, failed[0], strings.Join(failed[1:], ", "), firstErr)
+		}
+		return fmt.Errorf("Error generating pdf file for %s with %s: %w", l.BrfFile, failed[0], firstErr)
+	}
+
+	// finally append the enclosures to a copy of the generated pdf, which
+	// replaces the pdf file only once it is complete
+	tmpFile, err := utils.TempFileFor(pdfFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile)
+	err = copyFile(generatedFile, tmpFile)
+	if err != nil {
+		return fmt.Errorf("Cannot copy generated pdf file for %s: %w", l.BrfFile, err)
+	}
+	err = appendEnclosures(ctx, b.cfg, tmpFile, l.Enclosures())
+	if err != nil {
+		return fmt.Errorf("Cannot append enclosures to pdf file for %s: %w", l.BrfFile, err)
+	}
+
+	return utils.CommitTempFile(tmpFile, pdfFile)
+}
+
+// WriteTex generates the TeX file for the given Letter.
+//
+// An existing TeX file is only overwritten if it was generated by brief and
+// not modified since (see IsGeneratedTex) or if Force is set. Otherwise an
+// error wrapping ErrTexModified is returned.
+func (b *Latex) WriteTex(ctx context.Context, l *letter.Letter, texFile string) error {
+	return b.writeTex(ctx, l, texFile, b.Force)
+}
+
+// writeTex generates the TeX file for the given Letter. An existing TeX file
+// is only overwritten if it is unmodified or if force is true.
+func (b *Latex) writeTex(ctx context.Context, l *letter.Letter, texFile string, force bool) error {
+	if _, err := os.Stat(texFile); err == nil && !force && !IsGeneratedTex(texFile) {
+		return fmt.Errorf("Refusing to overwrite tex file %s: %w", texFile, ErrTexModified)
+	}
+
+	//TODO: These section names should be specified in an enum
+	texTemplate, err := parser.GetSingleValue(l.Brf.Sections["TEMPLATE"])
+	if err != nil {
+		return fmt.Errorf("Invalid tex template name: %s, \n%w", l.Brf.Sections["TEMPLATE"], err)
+	}
+
+	tmpl, err := template.ParseFiles(b.cfg.TexTemplateDir + "/" + texTemplate)
+	if err != nil {
+		return fmt.Errorf("Error reading tex template %s: %w", b.cfg.TexTemplateDir+"/"+texTemplate, err)
+	}
+
+	senderInput := senderToTemplateInput(l.Sender)
+	contentInput := b.contentToTemplateInput(ctx, l)
+	if _, ok := contentInput["ENCLOSURES"]; ok {
+		contentInput["ENCLOSURES"] = utfCharReplacer.Replace(strings.Join(l.EnclosureLines(), "\\\\\n"))
+	}
+	mergedInput := merge(merge(contentInput, senderInput), languageToTemplateInput(l.Locale))
+	mergedInput = merge(mergedInput, dateToTemplateInput(l))
+
+	mergedInput["SIGNATURE"], err = b.signatureToTemplateInput(ctx, l)
+	if err != nil {
+		return err
+	}
+
+	// fill the template first, as the header contains the hash of the result
+	content := &bytes.Buffer{}
+	err = tmpl.Execute(content, mergedInput)
+	if err != nil {
+		return fmt.Errorf("Error converting %s: %w", l.BrfFile, err)
+	}
+
+	//prepare the target file, which is only replaced once it is complete
+	f, err := utils.CreateAtomic(texFile)
+	if err != nil {
+		return fmt.Errorf("Could create output file %s: %w", texFile, err)
+	}
+	defer f.Abort()
+
+	//write to target file
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "%s from %s (sha1 %x). Manual changes protect this file from being overwritten.\n", TexMarker, filepath.Base(l.BrfFile), sha1.Sum(content.Bytes()))
+	_, err = content.WriteTo(w)
+	if err != nil {
+		return fmt.Errorf("Could not write output file %s: %w", texFile, err)
+	}
+	err = w.Flush()
+	if err != nil {
+		return fmt.Errorf("Could not write output file %s: %w", texFile, err)
+	}
+
+	return f.Commit()
+}
+
+// senderToTemplateInput converts an Address into a map of key-value-pairs
+// suitable to be filled into a brief template.
+func senderToTemplateInput(address address.Address) map[string]string {
+	r := make(map[string]string)
+	for k, v := range address.Fields {
+		r[k] = strings.Join(v, "\\\\\n")
+		r[k] = utfCharReplacer.Replace(r[k])
+	}
+
+	return r
+}
+
+// signatureToTemplateInput returns the LaTeX code to include the signature
+// image of the given Letter. The width of the image can be specified via the
+// 'signatureWidth' field of the sender and defaults to 4cm.
+//
+// SVG images are converted to PDF first, since LaTeX cannot include them
+// directly.
+//
+// If the letter has no signature image, an empty string is returned.
+func (b *Latex) signatureToTemplateInput(ctx context.Context, l *letter.Letter) (string, error) {
+	if l.SignatureFile == "" {
+		return "", nil
+	}
+
+	signatureFile, err := filepath.Abs(l.SignatureFile)
+	if err != nil {
+		return "", fmt.Errorf("Cannot determine absolute path of signature image %s: %w", l.SignatureFile, err)
+	}
+
+	if strings.ToLower(filepath.Ext(signatureFile)) == ".svg" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("Cannot determine cache directory for converted signature image: %w", err)
+		}
+		cacheDir = filepath.Join(cacheDir, "brief", "signatures")
+		err = os.MkdirAll(cacheDir, 0755)
+		if err != nil {
+			return "", fmt.Errorf("Cannot create cache directory for converted signature image: %w", err)
+		}
+
+		pdfFile := filepath.Join(cacheDir, fmt.Sprintf("%x.pdf", sha1.Sum([]byte(signatureFile))))
+		if !IsNewerThan(pdfFile, signatureFile) {
+			err = convertCached(ctx, b.cfg, signatureFile, pdfFile)
+			if err != nil {
+				return "", fmt.Errorf("Cannot convert signature image: %w", err)
+			}
+		}
+		signatureFile = pdfFile
+	}
+
+	width := l.Sender.Value("signatureWidth")
+	if width == "" {
+		width = "4cm"
+	}
+
+	return fmt.Sprintf("\\includegraphics[width=%s]{%s}", width, texPathReplacer.Replace(filepath.ToSlash(signatureFile))), nil
+}
+
+// convertCached converts the given image into the given PDF file in the
+// cache directory.
+//
+// The PDF file is only replaced once it is complete, as concurrent builds
+// may use the same cached file.
+func convertCached(ctx context.Context, cfg *config.Config, imageFile, pdfFile string) error {
+	tmpFile, err := utils.TempFileFor(pdfFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile)
+
+	err = convertImage(ctx, cfg, imageFile, tmpFile)
+	if err != nil {
+		return err
+	}
+	return utils.CommitTempFile(tmpFile, pdfFile)
+}
+
+// FIXME: Find a better name.
+// contentToTemplateInput converts the content of the sections of a .brf file into
+// a text suitable to be filled into a brief template.
+//
+// It does this by converting any markup in the CONTENT section into LaTeX
+// code, replacing straight quotes in plain text with the typographic
+// quotes of the letters language, replacing certain special characters
+// into LaTeX equivalents and replacing all newlines in sections other than
+// CONTENT into double backslashes (+ newline) to force a line break in
+// LaTeX.
+//
+// The returned map contains the section names as the key and their content
+// as the corresponding value.
+func (b *Latex) contentToTemplateInput(ctx context.Context, l *letter.Letter) map[string]string {
+	r := make(map[string]string)
+	for k, v := range l.Brf.Sections {
+		v = parser.TrimSurroundingEmptyLines(v)
+
+		// all sections except CONTENT get newlines replaced with double
+		// backslashes
+		if k != "CONTENT" {
+			r[k] = l.Locale.QuoteTex(strings.Join(v, "\\\\\n"))
+		} else {
+			r[k] = b.convertMarkup(ctx, v, l.Locale)
+		}
+
+		// Replace special characters to their LaTeX equivalents
+		r[k] = utfCharReplacer.Replace(r[k])
+
+		// TODO: Replace some brief-specific markup into LaTeX
+		//r[k] = inlineMarkupReplacer.Replace(r[k])
+	}
+
+	return r
+}
+
+// languageToTemplateInput returns the language specific values for a brief
+// template.
+//
+// These are the language code (LANGUAGE), the names of the language for
+// the babel and polyglossia packages (babelLanguage, polyglossiaLanguage)
+// and all labels of the language prefixed with 'label' (like
+// labelEnclosures).
+func languageToTemplateInput(l *locale.Locale) map[string]string {
+	r := make(map[string]string)
+	r["LANGUAGE"] = l.Code
+	r["babelLanguage"] = l.BabelName
+	r["polyglossiaLanguage"] = l.PolyglossiaName
+	for k, v := range l.Labels {
+		r["label"+strings.ToUpper(k[:1])+k[1:]] = utfCharReplacer.Replace(v)
+	}
+
+	return r
+}
+
+// dateToTemplateInput returns the date of the given Letter in several
+// formats for a brief template.
+//
+// These are dateLocale (the default format of the letters language, also
+// used for DATE), dateLong (including the weekday), dateShort (numeric) and
+// dateISO.
+func dateToTemplateInput(l *letter.Letter) map[string]string {
+	d := l.DateRenderings()
+	return map[string]string{
+		"dateLocale": utfCharReplacer.Replace(d["date"]),
+		"dateLong":   utfCharReplacer.Replace(d["date.long"]),
+		"dateShort":  d["date.short"],
+		"dateISO":    d["date.iso"],
+	}
+}
+
+// convertMarkup joins the given slice of strings with \n into a single
+// string.
+// If markup blocks are found in the given slice, those will be converted
+// first and then joined. Plain text gets its straight quotes replaced with
+// the typographic quotes of the given Locale (see locale.QuoteTex).
+// If markup conversion fails those lines will be joined as is (with the
+// surrounding markup block separators).
+func (b *Latex) convertMarkup(ctx context.Context, a []string, loc *locale.Locale) string {
+	sep := "\n"
+
+	blocks := markup.SplitBlocks(a)
+	converted := make([]string, len(blocks))
+	for i, block := range blocks {
+		if block.MarkupType == "" {
+			converted[i] = loc.QuoteTex(strings.Join(block.Lines, sep))
+			continue
+		}
+
+		mc, err := markup.NewConverter(block.MarkupType, loc.Code, markup.TargetLatex, b.cfg)
+		if err == nil {
+			converted[i], err = mc.Convert(ctx, block.Lines)
+		}
+		if err != nil {
+			log.Println(fmt.Errorf("Cannot convert markup %s. Leaving as is. %w", block.MarkupType, err))
+			converted[i] = strings.Join(block.Raw, sep)
+		}
+	}
+
+	return strings.Join(converted, sep)
+}
+
+// merge puts all entries in the given maps into a new map.
+// The original maps will not be altered
+// Keys that exist in both given maps get the value of the second one.
+func merge(m1, m2 map[string]string) map[string]string {
+	m := make(map[string]string, len(m1)+len(m2))
+
+	for k, v := range m1 {
+		m[k] = v
+	}
+
+	for k, v := range m2 {
+		m[k] = v
+	}
+
+	return m
+}
@@ -0,0 +1,126 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+	"poiu.de/brief/locale"
+	"poiu.de/brief/parser"
+)
+
+func TestWriteTex(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "t.tex"), []byte("\\documentclass{scrlttr2}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{TexTemplateDir: dir}
+	loc, err := locale.Get("de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &letter.Letter{
+		Locale:  loc,
+		BrfFile: filepath.Join(dir, "a.brf"),
+		Brf:     parser.Brf{Sections: map[string]parser.BrfLines{"TEMPLATE": {"t.tex"}}},
+	}
+	texFile := filepath.Join(dir, "a.tex")
+	b := NewLatex(cfg)
+
+	if err := b.WriteTex(context.Background(), l, texFile); err != nil {
+		t.Fatalf("WriteTex() failed: %v", err)
+	}
+	if !IsGeneratedTex(texFile) {
+		t.Fatalf("IsGeneratedTex() == false for generated tex file")
+	}
+	// unmodified files are overwritten
+	if err := b.WriteTex(context.Background(), l, texFile); err != nil {
+		t.Fatalf("WriteTex() over unmodified tex file failed: %v", err)
+	}
+
+	f, err := os.OpenFile(texFile, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("% manual change\n")
+	f.Close()
+	if IsGeneratedTex(texFile) {
+		t.Errorf("IsGeneratedTex() == true for modified tex file")
+	}
+	if err := b.WriteTex(context.Background(), l, texFile); !errors.Is(err, ErrTexModified) {
+		t.Errorf("WriteTex() over modified tex file returned %v, expected ErrTexModified", err)
+	}
+
+	b.Force = true
+	if err := b.WriteTex(context.Background(), l, texFile); err != nil {
+		t.Errorf("WriteTex() with Force failed: %v", err)
+	}
+	if !IsGeneratedTex(texFile) {
+		t.Errorf("IsGeneratedTex() == false after forced WriteTex()")
+	}
+}
+
+func TestSignatureToTemplateInput(t *testing.T) {
+	cases := []struct {
+		file     string
+		width    string
+		expected string
+	}{
+		{"", "", ""},
+		{"/sig/me.png", "", `\includegraphics[width=4cm]{/sig/me.png}`},
+		{"/sig/me.png", "3cm", `\includegraphics[width=3cm]{/sig/me.png}`},
+		{"/sig/100%_#1~me.png", "", `\includegraphics[width=4cm]{/sig/100\csname @percentchar\endcsname \string_\string#1\string~me.png}`},
+		{"/sig/{me}.png", "", `\includegraphics[width=4cm]{/sig/\csname @charlb\endcsname me\csname @charrb\endcsname .png}`},
+	}
+
+	b := NewLatex(&config.Config{})
+	for _, c := range cases {
+		l := &letter.Letter{SignatureFile: c.file}
+		if c.width != "" {
+			l.Sender.Fields = map[string]parser.BrfLines{"signatureWidth": {c.width}}
+		}
+		result, err := b.signatureToTemplateInput(context.Background(), l)
+		if err != nil {
+			t.Fatalf("signatureToTemplateInput() for %s failed: %v", c.file, err)
+		}
+		if result != c.expected {
+			t.Errorf("signatureToTemplateInput() for %s == %q, expected %q", c.file, result, c.expected)
+		}
+	}
+}
+
+func TestConvertCached(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "me.svg")
+	if err := os.WriteFile(image, []byte("svg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pdfFile := filepath.Join(dir, "cache", "me.pdf")
+	if err := os.MkdirAll(filepath.Dir(pdfFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// a failing conversion leaves neither the pdf file nor temporary files
+	cfg := &config.Config{ImageToPdfCommand: "sh -c 'echo partial > \"$2\"; exit 1' convert"}
+	if err := convertCached(context.Background(), cfg, image, pdfFile); err == nil {
+		t.Errorf("convertCached() with failing command succeeded")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(pdfFile)); len(entries) != 0 {
+		t.Errorf("convertCached() with failing command left %v", entries)
+	}
+
+	cfg.ImageToPdfCommand = "cp"
+	if err := convertCached(context.Background(), cfg, image, pdfFile); err != nil {
+		t.Fatalf("convertCached() failed: %v", err)
+	}
+	if content, err := os.ReadFile(pdfFile); err != nil || string(content) != "svg" {
+		t.Errorf("convertCached() wrote %q, %v, expected %q", content, err, "svg")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(pdfFile)); len(entries) != 1 {
+		t.Errorf("convertCached() left %v, expected only the pdf file", entries)
+	}
+}
@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"poiu.de/brief/config"
+)
+
+// The interval in which a held lock is checked again.
+const lockRetryInterval = 200 * time.Millisecond
+
+// LockExtension is the extension of the lock files created by Lock.
+const LockExtension = ".lock"
+
+// Lock acquires the lock for generating the given artifact, so that
+// concurrent builds of the same letter don't overwrite each other's files.
+//
+// The lock is a file next to the intermediate files in the build directory
+// (see BuildDir) that contains the process ID of its owner. If the lock is
+// held by another process, Lock waits until it is released or the given
+// context is cancelled. Locks of processes that no longer exist are
+// removed.
+//
+// The returned function releases the lock.
+func Lock(ctx context.Context, cfg *config.Config, file string) (func(), error) {
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot determine path of %s: %w", file, err)
+	}
+	buildDir := BuildDir(cfg, filepath.Dir(absFile))
+	err = os.MkdirAll(buildDir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot create build directory %s: %w", buildDir, err)
+	}
+	lockFile := filepath.Join(buildDir, filepath.Base(absFile)+LockExtension)
+
+	waiting := false
+	for {
+		f, err := os.OpenFile(lockFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = fmt.Fprintln(f, os.Getpid())
+			f.Close()
+			if err != nil {
+				os.Remove(lockFile)
+				return nil, fmt.Errorf("Cannot write lock file %s: %w", lockFile, err)
+			}
+			return func() { os.Remove(lockFile) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("Cannot create lock file %s: %w", lockFile, err)
+		}
+
+		if pid, ok := lockOwner(lockFile); ok && !processExists(pid) && removeStaleLock(lockFile, pid) {
+			continue
+		}
+
+		if !waiting {
+			log.Printf("Waiting for another build of %s to finish (lock file %s)", file, lockFile)
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("Cannot lock %s: %w", file, ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// IsActiveLock returns true if the given file is a lock file created by
+// Lock whose owner may still be running.
+func IsActiveLock(file string) bool {
+	return strings.HasSuffix(file, LockExtension) && !isStaleLock(file)
+}
+
+// isStaleLock returns true if the process that holds the given lock file no
+// longer exists.
+func isStaleLock(lockFile string) bool {
+	pid, ok := lockOwner(lockFile)
+	return ok && !processExists(pid)
+}
+
+// lockOwner returns the process ID of the owner of the given lock file.
+//
+// Lock files that cannot be read or don't contain a process ID (yet) have
+// no known owner, as their owner may be about to write them.
+func lockOwner(lockFile string) (int, bool) {
+	content, err := os.ReadFile(lockFile)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, false
+	}
+	return pid, true
+}
+
+// removeStaleLock removes the given lock file of the given process, which
+// no longer exists. It returns false if the lock file cannot be removed.
+//
+// Other builds waiting for the lock may remove the stale lock at the same
+// time and create a new one. To not remove such a new lock, the lock file is
+// moved to a unique name first, which succeeds for only one of them, and
+// moved back if it doesn't belong to the given process.
+func removeStaleLock(lockFile string, pid int) bool {
+	f, err := os.CreateTemp(filepath.Dir(lockFile), filepath.Base(lockFile)+".*.stale")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	staleFile := f.Name()
+	defer os.Remove(staleFile)
+
+	err = os.Rename(lockFile, staleFile)
+	if errors.Is(err, os.ErrNotExist) {
+		// already removed by another build
+		return true
+	} else if err != nil {
+		return false
+	}
+
+	if owner, ok := lockOwner(staleFile); !ok || owner != pid {
+		// fails if yet another build got the lock in the meantime
+		os.Link(staleFile, lockFile)
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"poiu.de/brief/config"
+)
+
+func TestLock(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{BuildDir: ".brief-build"}
+	pdfFile := filepath.Join(dir, "letter.pdf")
+	lockFile := filepath.Join(dir, ".brief-build", "letter.pdf.lock")
+
+	unlock, err := Lock(context.Background(), cfg, pdfFile)
+	if err != nil {
+		t.Fatalf("Lock() failed: %v", err)
+	}
+
+	// a second build waits until the lock is released
+	ctx, cancel := context.WithTimeout(context.Background(), 3*lockRetryInterval)
+	defer cancel()
+	if _, err := Lock(ctx, cfg, pdfFile); err == nil {
+		t.Errorf("Lock() of held lock succeeded, expected error")
+	}
+
+	unlock()
+	if _, err := os.Stat(lockFile); !os.IsNotExist(err) {
+		t.Errorf("Lock file still exists after unlock")
+	}
+
+	// locks of processes that no longer exist are removed
+	if err := os.WriteFile(lockFile, []byte("999999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlock, err = Lock(ctx, cfg, pdfFile)
+	if err != nil {
+		t.Fatalf("Lock() with stale lock file failed: %v", err)
+	}
+	unlock()
+}
+
+func TestLockConcurrentStaleLock(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{BuildDir: ".brief-build"}
+	pdfFile := filepath.Join(dir, "letter.pdf")
+	lockFile := filepath.Join(dir, ".brief-build", "letter.pdf.lock")
+	if err := os.MkdirAll(filepath.Dir(lockFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := os.WriteFile(lockFile, []byte("999999999\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// both builds see the stale lock, but only one of them may hold the
+		// lock at a time
+		var holders int32
+		var wg sync.WaitGroup
+		start := make(chan bool)
+		errs := make(chan error, 2)
+		for j := 0; j < 2; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				unlock, err := Lock(ctx, cfg, pdfFile)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if atomic.AddInt32(&holders, 1) != 1 {
+					errs <- fmt.Errorf("Lock() held by two builds at once")
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&holders, -1)
+				unlock()
+			}()
+		}
+		close(start)
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatalf("Concurrent Lock() with stale lock file failed: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(lockFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Lock files left behind: %v", entries)
+	}
+}
+
+func TestRemoveStaleLock(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "letter.pdf.lock")
+	fresh := fmt.Sprintln(os.Getpid())
+
+	// two builds saw the same stale lock: the first one removes it and
+	// creates a new lock, which the second one must not remove
+	if err := os.WriteFile(lockFile, []byte("999999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !removeStaleLock(lockFile, 999999999) {
+		t.Fatalf("removeStaleLock() failed")
+	}
+	if _, err := os.Stat(lockFile); !os.IsNotExist(err) {
+		t.Fatalf("Stale lock file still exists after removeStaleLock()")
+	}
+	if err := os.WriteFile(lockFile, []byte(fresh), 0644); err != nil {
+		t.Fatal(err)
+	}
+	removeStaleLock(lockFile, 999999999)
+	if content, err := os.ReadFile(lockFile); err != nil || string(content) != fresh {
+		t.Errorf("removeStaleLock() removed the new lock file: %q, %v", content, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(lockFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Files left behind by removeStaleLock(): %v", entries)
+	}
+}
@@ -0,0 +1,137 @@
+package backend
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"poiu.de/brief/config"
+)
+
+// ManifestName is the name of the build manifest in a build directory.
+//
+// The build manifest lists the final artifacts brief generated for the
+// letters of a directory, one path per line. The paths are relative to the
+// build directory. As artifacts are only ever appended, a path may be
+// listed multiple times and listed artifacts may no longer exist.
+const ManifestName = ".brief-manifest"
+
+// manifestMu serializes writes to build manifests, as letters may be
+// rendered in parallel (for example by the merge command).
+var manifestMu sync.Mutex
+
+// RecordArtifact adds the given file to the build manifest of the build
+// directory of its directory (see BuildDir).
+func RecordArtifact(cfg *config.Config, file string) error {
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return fmt.Errorf("Cannot determine path of artifact %s: %w", file, err)
+	}
+	buildDir := BuildDir(cfg, filepath.Dir(absFile))
+	entry, err := filepath.Rel(buildDir, absFile)
+	if err != nil {
+		entry = absFile
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	err = os.MkdirAll(buildDir, 0755)
+	if err != nil {
+		return fmt.Errorf("Cannot create build directory %s: %w", buildDir, err)
+	}
+	manifestFile := filepath.Join(buildDir, ManifestName)
+	f, err := os.OpenFile(manifestFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("Cannot open build manifest %s: %w", manifestFile, err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintln(f, filepath.ToSlash(entry))
+	if err != nil {
+		return fmt.Errorf("Cannot write build manifest %s: %w", manifestFile, err)
+	}
+	return nil
+}
+
+// ReadManifest returns the absolute paths of the artifacts listed in the
+// build manifest of the given build directory without duplicates.
+//
+// If there is no build manifest, an empty list is returned.
+func ReadManifest(buildDir string) ([]string, error) {
+	manifestFile := filepath.Join(buildDir, ManifestName)
+	f, err := os.Open(manifestFile)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read build manifest %s: %w", manifestFile, err)
+	}
+	defer f.Close()
+
+	absBuildDir, err := filepath.Abs(buildDir)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot determine path of build directory %s: %w", buildDir, err)
+	}
+
+	artifacts := []string{}
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" {
+			continue
+		}
+		artifact := filepath.FromSlash(entry)
+		if !filepath.IsAbs(artifact) {
+			artifact = filepath.Join(absBuildDir, artifact)
+		}
+		if !seen[artifact] {
+			seen[artifact] = true
+			artifacts = append(artifacts, artifact)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Cannot read build manifest %s: %w", manifestFile, err)
+	}
+	return artifacts, nil
+}
+
+// WriteManifest replaces the build manifest of the given build directory
+// with the given absolute paths of artifacts. If there are no artifacts,
+// the build manifest is removed.
+func WriteManifest(buildDir string, artifacts []string) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	manifestFile := filepath.Join(buildDir, ManifestName)
+	if len(artifacts) == 0 {
+		err := os.Remove(manifestFile)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Cannot remove build manifest %s: %w", manifestFile, err)
+		}
+		return nil
+	}
+
+	absBuildDir, err := filepath.Abs(buildDir)
+	if err != nil {
+		return fmt.Errorf("Cannot determine path of build directory %s: %w", buildDir, err)
+	}
+	b := &strings.Builder{}
+	for _, artifact := range artifacts {
+		entry, err := filepath.Rel(absBuildDir, artifact)
+		if err != nil {
+			entry = artifact
+		}
+		fmt.Fprintln(b, filepath.ToSlash(entry))
+	}
+
+	err = os.WriteFile(manifestFile, []byte(b.String()), 0644)
+	if err != nil {
+		return fmt.Errorf("Cannot write build manifest %s: %w", manifestFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"poiu.de/brief/config"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{BuildDir: ".brief-build"}
+	buildDir := filepath.Join(dir, ".brief-build")
+
+	artifacts, err := ReadManifest(buildDir)
+	if err != nil || len(artifacts) != 0 {
+		t.Errorf("ReadManifest() without manifest == %q, %v, expected no artifacts", artifacts, err)
+	}
+
+	for _, f := range []string{"a.pdf", "a.tex", "a.pdf"} {
+		if err := RecordArtifact(cfg, filepath.Join(dir, f)); err != nil {
+			t.Fatalf("RecordArtifact(%s) failed: %v", f, err)
+		}
+	}
+	artifacts, err = ReadManifest(buildDir)
+	if err != nil {
+		t.Fatalf("ReadManifest() failed: %v", err)
+	}
+	expected := []string{filepath.Join(dir, "a.pdf"), filepath.Join(dir, "a.tex")}
+	if !reflect.DeepEqual(artifacts, expected) {
+		t.Errorf("ReadManifest() == %q, expected %q", artifacts, expected)
+	}
+
+	if err := WriteManifest(buildDir, expected[1:]); err != nil {
+		t.Fatalf("WriteManifest() failed: %v", err)
+	}
+	artifacts, _ = ReadManifest(buildDir)
+	if !reflect.DeepEqual(artifacts, expected[1:]) {
+		t.Errorf("ReadManifest() after WriteManifest() == %q, expected %q", artifacts, expected[1:])
+	}
+
+	if err := WriteManifest(buildDir, nil); err != nil {
+		t.Fatalf("WriteManifest() without artifacts failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(buildDir, ManifestName)); !os.IsNotExist(err) {
+		t.Errorf("WriteManifest() without artifacts didn't remove the manifest")
+	}
+}
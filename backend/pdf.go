@@ -0,0 +1,109 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"poiu.de/brief/cmdline"
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+)
+
+// appendEnclosures appends the given enclosures to the given PDF file.
+//
+// Enclosures that are images are converted to PDF files first.
+func appendEnclosures(ctx context.Context, cfg *config.Config, pdfFile string, enclosures []letter.Enclosure) error {
+	if len(enclosures) == 0 {
+		return nil
+	}
+	if cfg.PdfJoinCommand == "" {
+		return fmt.Errorf("No pdf join command configured. Cannot append enclosures.")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "brief-enclosures-")
+	if err != nil {
+		return fmt.Errorf("Cannot create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	args := []string{cmdline.Quote(pdfFile)}
+	for i, e := range enclosures {
+		file := e.File
+		if e.IsImage() {
+			file = filepath.Join(tmpDir, fmt.Sprintf("enclosure-%d.pdf", i+1))
+			err := convertImage(ctx, cfg, e.File, file)
+			if err != nil {
+				return err
+			}
+		}
+		args = append(args, cmdline.Quote(file))
+	}
+
+	joinedFile := filepath.Join(tmpDir, "joined.pdf")
+	args = append(args, cmdline.Quote(joinedFile))
+
+	cmdCtx, cancel := cfg.CommandContext(ctx, config.TimeoutPdfJoin)
+	defer cancel()
+	cmdLine := cfg.PdfJoinCommand + " " + strings.Join(args, " ")
+	stdout := &strings.Builder{}
+	stderr := &strings.Builder{}
+	err = cmdline.ExecuteContext(cmdCtx, cmdLine, "", nil, stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("Error joining enclosures: %w", err)
+	}
+
+	return moveFile(joinedFile, pdfFile)
+}
+
+// convertImage converts the given image file into the given PDF file via the
+// configured ImageToPdfCommand.
+func convertImage(ctx context.Context, cfg *config.Config, imageFile, pdfFile string) error {
+	if cfg.ImageToPdfCommand == "" {
+		return fmt.Errorf("No image-to-pdf command configured. Cannot convert %s", imageFile)
+	}
+
+	cmdCtx, cancel := cfg.CommandContext(ctx, config.TimeoutImageToPdf)
+	defer cancel()
+	cmdLine := cfg.ImageToPdfCommand + " " + cmdline.Quote(imageFile) + " " + cmdline.Quote(pdfFile)
+	stdout := &strings.Builder{}
+	stderr := &strings.Builder{}
+	err := cmdline.ExecuteContext(cmdCtx, cmdLine, "", nil, stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("Error converting image %s: %w", imageFile, err)
+	}
+
+	return nil
+}
+
+// copyFile copies the file src to dst, replacing dst if it exists.
+func copyFile(src, dst string) error {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("Cannot read %s: %w", src, err)
+	}
+	err = os.WriteFile(dst, content, 0644)
+	if err != nil {
+		return fmt.Errorf("Cannot write %s: %w", dst, err)
+	}
+	return nil
+}
+
+// moveFile moves the file src to dst, replacing dst if it exists.
+//
+// Since src and dst may reside on different file systems, the file is
+// copied if renaming fails.
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+
+	err = copyFile(src, dst)
+	if err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
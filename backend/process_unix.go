@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package backend
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// processExists returns true if a process with the given ID exists.
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// signal 0 only checks whether the process can be signalled
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package backend
+
+import (
+	"os"
+)
+
+// processExists returns true if a process with the given ID exists.
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
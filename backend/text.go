@@ -0,0 +1,176 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+	"poiu.de/brief/locale"
+	"poiu.de/brief/markup"
+	"poiu.de/brief/parser"
+	"poiu.de/brief/utils"
+)
+
+// The default maximum width of the lines of the Text backend.
+const DefaultTextWidth = markup.DefaultWidth
+
+func init() {
+	Register("text", func(cfg *config.Config) Backend { return NewText(cfg) })
+}
+
+// Text is the Backend that renders letters as wrapped plain text.
+//
+// It utilizes the configured markup converters to convert markup blocks
+// (like markdown or asciidoc) to plain text.
+type Text struct {
+	cfg *config.Config
+	// The maximum width of the lines.
+	Width int
+	// Whether to render the letter as email body. This leaves out the postal
+	// address of the recipient.
+	Email bool
+}
+
+// NewText creates a new Text backend for the given configuration with the
+// DefaultTextWidth.
+func NewText(cfg *config.Config) *Text {
+	return &Text{cfg: cfg, Width: DefaultTextWidth}
+}
+
+// Name returns the name of this Backend.
+func (b *Text) Name() string {
+	return "text"
+}
+
+// Extension returns the file extension of the artifacts of this Backend.
+func (b *Text) Extension() string {
+	return "txt"
+}
+
+// DependsOn returns the files the text file of the given Letter depends
+// on.
+func (b *Text) DependsOn(l *letter.Letter) []string {
+	return letterDependencies(l, b.cfg)
+}
+
+// Render writes the given Letter as plain text into the given file.
+func (b *Text) Render(ctx context.Context, l *letter.Letter, textFile string) error {
+	err := os.WriteFile(textFile, []byte(b.RenderText(ctx, l)), 0644)
+	if err != nil {
+		return fmt.Errorf("Could not write output file %s: %w", textFile, err)
+	}
+	return nil
+}
+
+// RenderText returns the given Letter as plain text.
+//
+// The sender block is followed by the recipient block (unless rendering
+// an email body), the right aligned date, the subject, the content, the
+// name of the sender and the enclosures.
+func (b *Text) RenderText(ctx context.Context, l *letter.Letter) string {
+	blocks := make([]string, 0)
+
+	blocks = append(blocks, joinLines(l.Sender.Fields["fromName"], l.Sender.Fields["fromAddress"]))
+	if !b.Email {
+		blocks = append(blocks, joinLines(parser.TrimSurroundingEmptyLines(l.Brf.Sections["TO"])))
+	}
+
+	date := strings.Join(parser.TrimSurroundingEmptyLines(l.Brf.Sections["DATE"]), " ")
+	if date != "" {
+		if padding := b.Width - len([]rune(date)); padding > 0 {
+			date = strings.Repeat(" ", padding) + date
+		}
+		blocks = append(blocks, date)
+	}
+
+	subject := strings.Join(parser.TrimSurroundingEmptyLines(l.Brf.Sections["SUBJECT"]), " ")
+	if subject != "" {
+		blocks = append(blocks, joinLines(utils.WrapText(l.Locale.Quote(subject), b.Width)))
+	}
+
+	blocks = append(blocks, b.convertMarkup(ctx, parser.TrimSurroundingEmptyLines(l.Brf.Sections["CONTENT"]), l.Locale))
+	blocks = append(blocks, joinLines(l.Sender.Fields["fromName"]))
+
+	if _, ok := l.Brf.Sections["ENCLOSURES"]; ok {
+		blocks = append(blocks, joinLines(l.EnclosureLines()))
+	}
+
+	r := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		if strings.TrimSpace(b) != "" {
+			r = append(r, strings.TrimRight(b, "\n"))
+		}
+	}
+	return strings.Join(r, "\n\n") + "\n"
+}
+
+// convertMarkup converts the given lines of the CONTENT section into
+// wrapped plain text.
+//
+// Markup blocks are converted via the configured markup converters, which
+// get the line width passed via the placeholder %w. Plain
+// text is split into paragraphs at empty lines, wrapped and gets its
+// straight quotes replaced with the typographic quotes of the given Locale.
+// If markup conversion fails those lines will be included as is (with the
+// surrounding markup block separators).
+func (b *Text) convertMarkup(ctx context.Context, a []string, loc *locale.Locale) string {
+	blocks := markup.SplitBlocks(a)
+	converted := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.MarkupType == "" {
+			for _, p := range paragraphs(block.Lines) {
+				converted = append(converted, joinLines(utils.WrapText(loc.Quote(strings.Join(p, "\n")), b.Width)))
+			}
+			continue
+		}
+
+		mc, err := markup.NewConverter(block.MarkupType, loc.Code, markup.TargetPlain, b.cfg)
+		var s string
+		if err == nil {
+			mc.SetWidth(b.Width)
+			s, err = mc.Convert(ctx, block.Lines)
+		}
+		if err != nil {
+			log.Println(fmt.Errorf("Cannot convert markup %s. Leaving as is. %w", block.MarkupType, err))
+			s = joinLines(block.Raw)
+		}
+		converted = append(converted, strings.Trim(s, "\n"))
+	}
+
+	return strings.Join(converted, "\n\n")
+}
+
+// paragraphs splits the given lines into paragraphs separated by empty
+// lines.
+func paragraphs(lines []string) [][]string {
+	r := make([][]string, 0)
+	p := make([]string, 0)
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			if len(p) > 0 {
+				r = append(r, p)
+				p = make([]string, 0)
+			}
+			continue
+		}
+		p = append(p, line)
+	}
+	if len(p) > 0 {
+		r = append(r, p)
+	}
+
+	return r
+}
+
+// joinLines joins all given slices of lines with newlines.
+func joinLines(lines ...[]string) string {
+	all := make([]string, 0)
+	for _, l := range lines {
+		all = append(all, l...)
+	}
+	return strings.Join(all, "\n")
+}
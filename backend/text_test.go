@@ -0,0 +1,106 @@
+package backend
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"poiu.de/brief/address"
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+	"poiu.de/brief/locale"
+	"poiu.de/brief/parser"
+)
+
+func TestRenderText(t *testing.T) {
+	en, err := locale.Get("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections := map[string]parser.BrfLines{
+		"TO":      {"", "Jane Doe", "Main St 1", ""},
+		"DATE":    {"March 3, 2020"},
+		"SUBJECT": {"Your \"offer\" of last week"},
+		"CONTENT": {"", "Dear Jane,", "", "thank you for the offer, which we accept.", "Regards", ""},
+	}
+	sender := address.Address{Fields: map[string]parser.BrfLines{"fromName": {"Max Mustermann"}, "fromAddress": {"Side St 2"}}}
+
+	cases := []struct {
+		email      bool
+		enclosures parser.BrfLines
+		expected   string
+	}{
+		{false, nil, `Max Mustermann
+Side St 2
+
+Jane Doe
+Main St 1
+
+       March 3, 2020
+
+Your “offer” of last
+week
+
+Dear Jane,
+
+thank you for the
+offer, which we
+accept. Regards
+
+Max Mustermann
+`},
+		{true, parser.BrfLines{"contract.pdf | Contract", "scan.png"}, `Max Mustermann
+Side St 2
+
+       March 3, 2020
+
+Your “offer” of last
+week
+
+Dear Jane,
+
+thank you for the
+offer, which we
+accept. Regards
+
+Max Mustermann
+
+Enclosure 1: Contract
+Enclosure 2: scan
+`},
+	}
+
+	for _, c := range cases {
+		l := &letter.Letter{BrfFile: "a.brf", Sender: sender, Locale: en, Brf: parser.Brf{Sections: map[string]parser.BrfLines{}}}
+		for k, v := range sections {
+			l.Brf.Sections[k] = v
+		}
+		if c.enclosures != nil {
+			l.Brf.Sections["ENCLOSURES"] = c.enclosures
+		}
+
+		b := NewText(&config.Config{})
+		b.Width = 20
+		b.Email = c.email
+		if result := b.RenderText(context.Background(), l); result != c.expected {
+			t.Errorf("RenderText() with email %t == %q, expected %q", c.email, result, c.expected)
+		}
+	}
+}
+
+func TestParagraphs(t *testing.T) {
+	cases := []struct {
+		lines    []string
+		expected [][]string
+	}{
+		{[]string{}, [][]string{}},
+		{[]string{"", " ", ""}, [][]string{}},
+		{[]string{"a", "b", "", "", "c", " "}, [][]string{{"a", "b"}, {"c"}}},
+	}
+
+	for _, c := range cases {
+		if result := paragraphs(c.lines); !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("paragraphs(%q) == %q, expected %q", c.lines, result, c.expected)
+		}
+	}
+}
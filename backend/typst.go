@@ -0,0 +1,394 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"poiu.de/brief/cmdline"
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+	"poiu.de/brief/locale"
+	"poiu.de/brief/markup"
+	"poiu.de/brief/parser"
+	"poiu.de/brief/utils"
+)
+
+var (
+	// Replacer to escape characters with a special meaning in Typst markup
+	typstCharReplacer = strings.NewReplacer(
+		`\`, `\\`,
+		`#`, `\#`,
+		`$`, `\$`,
+		`*`, `\*`,
+		`_`, `\_`,
+		"`", "\\`",
+		`<`, `\<`,
+		`>`, `\>`,
+		`@`, `\@`,
+		`[`, `\[`,
+		`]`, `\]`,
+		`~`, `\~`,
+		`/`, `\/`,
+	)
+
+	// Regex for characters that start a heading or list in Typst markup if
+	// they appear at the start of a line
+	patternTypstLineStart *regexp.Regexp = regexp.MustCompile(`(?m)^(\s*)([=+-])`)
+	// Regex for a number followed by a dot that starts an enumeration in
+	// Typst markup if it appears at the start of a line
+	patternTypstEnumStart *regexp.Regexp = regexp.MustCompile(`(?m)^(\s*\d+)\.`)
+)
+
+// defaultTypstTemplate is used if no Typst template exists for the template
+// of a letter.
+const defaultTypstTemplate = `#set page(paper: "a4", margin: (x: 2.5cm, y: 2.5cm))
+#set text(lang: "{{.LANGUAGE}}", size: 11pt)
+#set par(justify: true)
+
+#align(right)[{{.fromName}} \
+{{.fromAddress}}]
+
+#v(1cm)
+{{.TO}}
+
+#v(1cm)
+#align(right)[{{.DATE}}]
+
+*{{.SUBJECT}}*
+
+{{.CONTENT}}
+
+{{.SIGNATURE}}
+
+{{.fromName}}
+{{- with .ENCLOSURES}}
+
+#v(0.5cm)
+{{.}}
+{{- end}}
+`
+
+func init() {
+	Register("typst", func(cfg *config.Config) Backend { return NewTypst(cfg) })
+}
+
+// Typst is the Backend that generates PDF files via Typst.
+//
+// It fills the content of a letter into a Typst template and calls the
+// typst compiler to generate the PDF file from the resulting Typst file.
+// It utilizes the configured markup converters to convert markup blocks
+// (like markdown or asciidoc) to Typst code.
+type Typst struct {
+	cfg *config.Config
+}
+
+// NewTypst creates a new Typst backend for the given configuration.
+func NewTypst(cfg *config.Config) *Typst {
+	return &Typst{cfg: cfg}
+}
+
+// Name returns the name of this Backend.
+func (b *Typst) Name() string {
+	return "typst"
+}
+
+// Extension returns the file extension of the artifacts of this Backend.
+func (b *Typst) Extension() string {
+	return "pdf"
+}
+
+// Validate checks whether the given Letter can be rendered via Typst.
+//
+// Typst cannot include PDF files, therefore PDF signature images are
+// rejected.
+func (b *Typst) Validate(l *letter.Letter) error {
+	if strings.ToLower(filepath.Ext(l.SignatureFile)) == ".pdf" {
+		return fmt.Errorf("Invalid letter %s:\n  Unsupported signature image %s. The typst backend only supports PNG, JPEG and SVG files.", l.BrfFile, l.SignatureFile)
+	}
+	return nil
+}
+
+// DependsOn returns the files the PDF file of the given Letter depends on.
+// These are the files of the letter itself and the Typst template (if one
+// exists).
+func (b *Typst) DependsOn(l *letter.Letter) []string {
+	deps := letterDependencies(l, b.cfg)
+	if typstTemplate := b.templateFile(l); typstTemplate != "" {
+		deps = append(deps, typstTemplate)
+	}
+	return deps
+}
+
+// Render generates the PDF file for the given Letter.
+//
+// The intermediate Typst file is written next to the PDF file. The
+// enclosures of the letter are appended to the generated PDF file.
+func (b *Typst) Render(ctx context.Context, l *letter.Letter, pdfFile string) error {
+	if b.cfg.TypstCommand == "" {
+		return fmt.Errorf("No typst command configured. Cannot produce pdf file.")
+	}
+
+	typFile := utils.DeriveFilePath(pdfFile, "typ")
+	err := b.WriteTypst(ctx, l, typFile)
+	if err != nil {
+		return fmt.Errorf("Cannot create typst file for %s: %w", l.BrfFile, err)
+	}
+	// the typst file is no intermediate file of the build directory, so
+	// remember it for the clean command
+	err = RecordArtifact(b.cfg, typFile)
+	if err != nil {
+		log.Println(err)
+	}
+
+	// the pdf file is only replaced once it is complete
+	tmpFile, err := utils.TempFileFor(pdfFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile)
+
+	cmdCtx, cancel := b.cfg.CommandContext(ctx, config.TimeoutTypst)
+	defer cancel()
+	placeholders := cmdline.FilePlaceholders(filepath.Base(typFile), filepath.Base(tmpFile))
+	stdout := &strings.Builder{}
+	stderr := &strings.Builder{}
+	err = cmdline.ExecuteWithPlaceholders(cmdCtx, typstCommandLine(b.cfg.TypstCommand), placeholders, filepath.Dir(typFile), nil, stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("Error generating pdf file for %s: %w", l.BrfFile, err)
+	}
+
+	err = appendEnclosures(ctx, b.cfg, tmpFile, l.Enclosures())
+	if err != nil {
+		return fmt.Errorf("Cannot append enclosures to pdf file for %s: %w", l.BrfFile, err)
+	}
+
+	return utils.CommitTempFile(tmpFile, pdfFile)
+}
+
+// typstCommandLine returns the given TypstCommand with the placeholders for
+// the Typst file and the PDF file appended, unless it refers to them
+// already.
+//
+// The command line may contain the placeholders of cmdline.FilePlaceholders
+// with %o being the PDF file instead of a directory. It is executed in the
+// directory of the Typst file and the paths are relative to it.
+func typstCommandLine(command string) string {
+	command = cmdline.WithFile(command)
+	if !cmdline.HasPlaceholder(command, 'o') {
+		command += " %o"
+	}
+	return command
+}
+
+// WriteTypst generates the Typst file for the given Letter.
+func (b *Typst) WriteTypst(ctx context.Context, l *letter.Letter, typFile string) error {
+	tmpl, err := b.typstTemplate(l)
+	if err != nil {
+		return err
+	}
+
+	input := make(map[string]string)
+	for k, v := range l.Brf.Sections {
+		v = parser.TrimSurroundingEmptyLines(v)
+		if k != "CONTENT" {
+			input[k] = typstLines(strings.Split(l.Locale.Quote(strings.Join(v, "\n")), "\n"))
+		} else {
+			input[k] = b.convertMarkup(ctx, v, l.Locale)
+		}
+	}
+	for k, v := range l.Sender.Fields {
+		input[k] = typstLines(v)
+	}
+	input["LANGUAGE"] = l.Locale.Code
+	for k, v := range l.Locale.Labels {
+		input["label"+strings.ToUpper(k[:1])+k[1:]] = typstEscape(v)
+	}
+	d := l.DateRenderings()
+	input["dateLocale"] = typstEscape(d["date"])
+	input["dateLong"] = typstEscape(d["date.long"])
+	input["dateShort"] = typstEscape(d["date.short"])
+	input["dateISO"] = d["date.iso"]
+	if _, ok := input["ENCLOSURES"]; ok {
+		input["ENCLOSURES"] = typstLines(l.EnclosureLines())
+	}
+	input["SIGNATURE"] = signatureToTypstInput(l, b.cfg, typFile)
+
+	//prepare the target file, which is only replaced once it is complete
+	f, err := utils.CreateAtomic(typFile)
+	if err != nil {
+		return fmt.Errorf("Could create output file %s: %w", typFile, err)
+	}
+	defer f.Abort()
+
+	//write to target file (using template)
+	w := bufio.NewWriter(f)
+	err = tmpl.Execute(w, input)
+	if err != nil {
+		return fmt.Errorf("Error converting %s: %w", l.BrfFile, err)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return fmt.Errorf("Could not write output file %s: %w", typFile, err)
+	}
+
+	return f.Commit()
+}
+
+// templateFile returns the Typst template file for the given Letter.
+//
+// This is the file in the Typst template directory with the same name as
+// the tex template of the letter, but with the extension .typ. If no such
+// file exists, an empty string is returned.
+func (b *Typst) templateFile(l *letter.Letter) string {
+	texTemplate, err := parser.GetSingleValue(l.Brf.Sections["TEMPLATE"])
+	if err != nil || b.cfg.TypstTemplateDir == "" {
+		return ""
+	}
+
+	typstTemplate := filepath.Join(b.cfg.TypstTemplateDir, utils.DeriveFilePath(texTemplate, "typ"))
+	if _, err := os.Stat(typstTemplate); err != nil {
+		return ""
+	}
+	return typstTemplate
+}
+
+// typstTemplate returns the Typst template for the given Letter.
+//
+// If no Typst template file exists for the letter, a built-in default
+// template is used.
+func (b *Typst) typstTemplate(l *letter.Letter) (*template.Template, error) {
+	typstTemplate := b.templateFile(l)
+	if typstTemplate == "" {
+		return template.New("default").Parse(defaultTypstTemplate)
+	}
+
+	tmpl, err := template.ParseFiles(typstTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading typst template %s: %w", typstTemplate, err)
+	}
+	return tmpl, nil
+}
+
+// convertMarkup converts the given lines of the CONTENT section into Typst
+// markup.
+//
+// Markup blocks are converted via the configured markup converters. Plain
+// text gets its special characters escaped and its straight quotes
+// replaced with the typographic quotes of the given Locale.
+// If markup conversion fails those lines will be included as escaped text
+// (with the surrounding markup block separators).
+func (b *Typst) convertMarkup(ctx context.Context, a []string, loc *locale.Locale) string {
+	blocks := markup.SplitBlocks(a)
+	converted := make([]string, len(blocks))
+	for i, block := range blocks {
+		if block.MarkupType == "" {
+			converted[i] = typstEscape(loc.Quote(strings.Join(block.Lines, "\n")))
+			continue
+		}
+
+		mc, err := markup.NewConverter(block.MarkupType, loc.Code, markup.TargetTypst, b.cfg)
+		if err == nil {
+			converted[i], err = mc.Convert(ctx, block.Lines)
+		}
+		if err != nil {
+			log.Println(fmt.Errorf("Cannot convert markup %s. Leaving as is. %w", block.MarkupType, err))
+			converted[i] = typstEscape(strings.Join(block.Raw, "\n"))
+		}
+	}
+
+	return strings.Join(converted, "\n")
+}
+
+// signatureToTypstInput returns the Typst code to include the signature
+// image of the given Letter into the given Typst file. The width of the
+// image can be specified via the 'signatureWidth' field of the sender and
+// defaults to 4cm.
+//
+// If the letter has no signature image, an empty string is returned.
+func signatureToTypstInput(l *letter.Letter, cfg *config.Config, typFile string) string {
+	if l.SignatureFile == "" {
+		return ""
+	}
+	signatureFile, err := copySignature(l.SignatureFile, cfg, typFile)
+	if err != nil {
+		log.Println(fmt.Errorf("Cannot include signature image %s via typst. Skipping it. %w", l.SignatureFile, err))
+		return ""
+	}
+
+	width := l.Sender.Value("signatureWidth")
+	if width == "" {
+		width = "4cm"
+	}
+
+	return fmt.Sprintf("#image(%s, width: %s)", typstString(signatureFile), width)
+}
+
+// copySignature copies the given signature image to where it can be
+// included from the given Typst file and returns the path of the copy
+// relative to the Typst file.
+//
+// Typst only includes files below its root directory, which is the
+// directory of the Typst file. Therefore the image is copied into the build
+// directory, or next to the Typst file if the build directory is outside of
+// that directory.
+func copySignature(signatureFile string, cfg *config.Config, typFile string) (string, error) {
+	absTypFile, err := filepath.Abs(typFile)
+	if err != nil {
+		return "", fmt.Errorf("Cannot determine path of %s: %w", typFile, err)
+	}
+	dir := filepath.Dir(absTypFile)
+	name := strings.TrimSuffix(filepath.Base(typFile), filepath.Ext(typFile)) + ".signature" + strings.ToLower(filepath.Ext(signatureFile))
+
+	copied := filepath.Join(BuildDir(cfg, dir), name)
+	rel, err := filepath.Rel(dir, copied)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		copied = filepath.Join(dir, name)
+		rel = name
+		// remember the copy for the clean command
+		err = RecordArtifact(cfg, copied)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	err = os.MkdirAll(filepath.Dir(copied), 0755)
+	if err != nil {
+		return "", fmt.Errorf("Cannot create directory for %s: %w", copied, err)
+	}
+	err = copyFile(signatureFile, copied)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// typstEscape escapes all characters in the given text that have a special
+// meaning in Typst markup.
+func typstEscape(s string) string {
+	s = typstCharReplacer.Replace(s)
+	s = patternTypstLineStart.ReplaceAllString(s, `$1\$2`)
+	return patternTypstEnumStart.ReplaceAllString(s, `$1\.`)
+}
+
+// typstLines escapes the given lines for Typst and joins them with forced
+// line breaks.
+func typstLines(lines []string) string {
+	escaped := make([]string, len(lines))
+	for i, line := range lines {
+		escaped[i] = typstEscape(line)
+	}
+	return strings.Join(escaped, " \\\n")
+}
+
+// typstString returns the given text as a Typst string literal.
+func typstString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+)
+
+func TestTypstEscape(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"plain text", "plain text"},
+		{"#set *bold* _em_ $x$", `\#set \*bold\* \_em\_ \$x\$`},
+		{"a@b.de <label> [x]", `a\@b.de \<label\> \[x\]`},
+		{"http://example.org // no comment", `http:\/\/example.org \/\/ no comment`},
+		{"= no heading\n- no list\n+ no enum", "\\= no heading\n\\- no list\n\\+ no enum"},
+		{"18. Oktober 2024", `18\. Oktober 2024`},
+		{"Seite 2. Absatz - x", "Seite 2. Absatz - x"},
+		{`C:\path`, `C:\\path`},
+	}
+
+	for _, c := range cases {
+		result := typstEscape(c.input)
+		if result != c.expected {
+			t.Errorf("typstEscape(%q) == %q, expected %q", c.input, result, c.expected)
+		}
+	}
+}
+
+func TestSignatureToTypstInput(t *testing.T) {
+	dir := t.TempDir()
+	signature := filepath.Join(t.TempDir(), "Me.PNG")
+	if err := os.WriteFile(signature, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+
+	cases := []struct {
+		buildDir string
+		expected string
+		copied   string
+	}{
+		{".brief-build", `#image(".brief-build/a.signature.png", width: 4cm)`, filepath.Join(dir, ".brief-build", "a.signature.png")},
+		{"", `#image("a.signature.png", width: 4cm)`, filepath.Join(dir, "a.signature.png")},
+		{outside, `#image("a.signature.png", width: 4cm)`, filepath.Join(dir, "a.signature.png")},
+	}
+
+	for _, c := range cases {
+		cfg := &config.Config{BuildDir: c.buildDir}
+		l := &letter.Letter{SignatureFile: signature}
+		result := signatureToTypstInput(l, cfg, filepath.Join(dir, "a.typ"))
+		if result != c.expected {
+			t.Errorf("signatureToTypstInput() with build dir %q == %q, expected %q", c.buildDir, result, c.expected)
+		}
+		if _, err := os.Stat(c.copied); err != nil {
+			t.Errorf("signatureToTypstInput() with build dir %q did not copy the signature: %v", c.buildDir, err)
+		}
+		os.Remove(c.copied)
+	}
+
+}
+
+func TestTypstValidate(t *testing.T) {
+	b := NewTypst(&config.Config{})
+	cases := []struct {
+		signature string
+		valid     bool
+	}{
+		{"", true},
+		{"me.png", true},
+		{"me.svg", true},
+		{"me.pdf", false},
+		{"ME.PDF", false},
+	}
+
+	for _, c := range cases {
+		err := b.Validate(&letter.Letter{BrfFile: "a.brf", SignatureFile: c.signature})
+		if (err == nil) != c.valid {
+			t.Errorf("Validate() for signature %q == %v, expected valid: %t", c.signature, err, c.valid)
+		}
+	}
+}
+
+func TestTypstCommandLine(t *testing.T) {
+	cases := []struct {
+		command  string
+		expected string
+	}{
+		{"typst compile", "typst compile %f %o"},
+		{"typst compile --root .. %f", "typst compile --root .. %f %o"},
+		{"typst compile %b.typ %o", "typst compile %b.typ %o"},
+		{"my-typst -o %o", "my-typst -o %o %f"},
+	}
+
+	for _, c := range cases {
+		if result := typstCommandLine(c.command); result != c.expected {
+			t.Errorf("typstCommandLine(%q) == %q, expected %q", c.command, result, c.expected)
+		}
+	}
+}
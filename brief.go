@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"gopkg.in/alecthomas/kingpin.v2"
 	"poiu.de/brief/cmd"
+	"poiu.de/brief/cmdline"
 	"poiu.de/brief/config"
 )
 
@@ -13,6 +19,15 @@ func prepareCommands() {
 
 	cfg := config.NewConfig()
 
+	var verbose bool
+	app.Flag("verbose", "Print the command lines and the output of external commands.").Short('v').BoolVar(&verbose)
+	app.PreAction(func(ctx *kingpin.ParseContext) error {
+		if verbose {
+			cmdline.VerboseOutput = os.Stderr
+		}
+		return nil
+	})
+
 	//TODO: Put into cmd/tex.go#init()?
 	//      Doesn't work. We would still need the reference to 'app'
 	texCmd := &cmd.TexCommand{Config: *cfg}
@@ -21,12 +36,100 @@ func prepareCommands() {
 	pdfCmd := &cmd.PdfCommand{Config: *cfg}
 	pdfCmd.Configure(app)
 
+	htmlCmd := &cmd.HtmlCommand{Config: *cfg}
+	htmlCmd.Configure(app)
+
+	textCmd := &cmd.TextCommand{Config: *cfg}
+	textCmd.Configure(app)
+
 	previewCmd := &cmd.PreviewCommand{Config: *cfg}
 	previewCmd.Configure(app)
 
-	kingpin.MustParse(app.Parse(os.Args[1:]))
+	printCmd := &cmd.PrintCommand{Config: *cfg}
+	printCmd.Configure(app)
+
+	validateCmd := &cmd.ValidateCommand{Config: *cfg}
+	validateCmd.Configure(app)
+
+	mailCmd := &cmd.MailCommand{Config: *cfg}
+	mailCmd.Configure(app)
+
+	mergeCmd := &cmd.MergeCommand{Config: *cfg}
+	mergeCmd.Configure(app)
+
+	listCmd := &cmd.ListCommand{Config: *cfg}
+	listCmd.Configure(app)
+
+	searchCmd := &cmd.SearchCommand{Config: *cfg}
+	searchCmd.Configure(app)
+
+	editCmd := &cmd.EditCommand{Config: *cfg}
+	editCmd.Configure(app)
+
+	createCmd := &cmd.CreateCommand{Config: *cfg}
+	createCmd.Configure(app)
+
+	cleanCmd := &cmd.CleanCommand{Config: *cfg}
+	cleanCmd.Configure(app)
+
+	_, err := app.Parse(os.Args[1:])
+	if err != nil {
+		reportError(app, err, verbose)
+	}
+}
+
+// reportError prints the given error and exits the application.
+//
+// If the error was caused by a failing external command, the last lines of
+// its output are printed as well (unless the output was already printed in
+// verbose mode). If the application was interrupted, it exits with the
+// exit code 130 like a shell does.
+func reportError(app *kingpin.Application, err error, verbose bool) {
+	if cmd.Context.Err() != nil {
+		app.Errorf("%s", err)
+		os.Exit(130)
+	}
+
+	var execErr *cmdline.ExecError
+	if !errors.As(err, &execErr) {
+		app.Fatalf("%s, try --help", err)
+	}
+
+	app.Errorf("%s", err)
+	if !verbose {
+		fmt.Fprint(os.Stderr, execErr.Details())
+	}
+	os.Exit(1)
+}
+
+// handleInterrupts returns a context that gets cancelled when the
+// application is interrupted (via Ctrl-C or SIGTERM).
+//
+// Cancelling the context terminates all running external commands, so
+// the running BriefCmd returns and cleans up after itself (like removing
+// locks and temporary files). Afterwards the default handling of the
+// signals is restored, so a second interrupt exits the application
+// immediately (for example if it waits for user input).
+func handleInterrupts() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+		signal.Stop(signals)
+	}()
+
+	return ctx
 }
 
 func main() {
+	cmd.Context = handleInterrupts()
+
 	prepareCommands()
+
+	if cmd.Context.Err() != nil {
+		os.Exit(130)
+	}
 }
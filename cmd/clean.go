@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+	"poiu.de/brief/backend"
+	"poiu.de/brief/config"
+	"poiu.de/brief/utils"
+)
+
+var (
+	// The extensions of the auxiliary files LaTeX engines write next to a
+	// TeX file.
+	intermediateExtensions = []string{"aux", "log", "out", "toc", "fls", "fdb_latexmk", "synctex.gz"}
+)
+
+// CleanCommand removes the files generated by brief.
+//
+// Only files known to be generated by brief are removed: the files in build
+// directories, the artifacts listed in build manifests, temporary files of
+// interrupted builds and TeX files
+// that are unmodified since they were generated (together with their
+// auxiliary files).
+type CleanCommand struct {
+	// The files and directories to clean.
+	paths []string
+	// Whether to only print the files that would be removed.
+	dryRun bool
+	// Whether to keep the generated PDF files.
+	keepPdf bool
+	// The configuration for this BriefCmd.
+	Config config.Config
+}
+
+// Configure configures the command line parser for this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.Application.
+func (c *CleanCommand) Configure(app *kingpin.Application) {
+	clean := app.Command("clean", "Remove the files generated by brief from the given <paths> (or the document roots and output directory).").Action(c.run)
+	clean.Arg("paths", "Files or directories to clean recursively.").StringsVar(&c.paths)
+	clean.Flag("dry-run", "Only print the files that would be removed.").Short('n').BoolVar(&c.dryRun)
+	clean.Flag("keep-pdf", "Keep the generated pdf files.").BoolVar(&c.keepPdf)
+}
+
+// Run executes this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.ParseContext.
+func (c *CleanCommand) run(ctx *kingpin.ParseContext) error {
+	paths := c.paths
+	if len(paths) == 0 {
+		paths = c.Config.DocumentRoots
+		if c.Config.OutputDir != "" && fileExists(c.Config.OutputDir) {
+			paths = append(append([]string{}, paths...), c.Config.OutputDir)
+		}
+	}
+
+	cl := newCleaner(&c.Config, c.keepPdf)
+	for _, path := range paths {
+		err := cl.collect(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, f := range cl.files {
+		if c.dryRun {
+			fmt.Printf("Would remove %s\n", f)
+			continue
+		}
+		err := os.Remove(f)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Cannot remove %s: %w", f, err)
+		}
+		fmt.Printf("Removed %s\n", f)
+	}
+
+	if c.dryRun {
+		return nil
+	}
+	return cl.updateBuildDirs()
+}
+
+// cleaner collects the files generated by brief.
+type cleaner struct {
+	cfg     *config.Config
+	keepPdf bool
+	// The collected files in the order they were found.
+	files []string
+	// The collected files as a set.
+	collected map[string]bool
+	// The artifacts of the build manifests by their build directory.
+	manifests map[string][]string
+}
+
+// newCleaner creates a new cleaner for the given configuration.
+func newCleaner(cfg *config.Config, keepPdf bool) *cleaner {
+	return &cleaner{
+		cfg:       cfg,
+		keepPdf:   keepPdf,
+		files:     []string{},
+		collected: make(map[string]bool),
+		manifests: make(map[string][]string),
+	}
+}
+
+// collect collects the generated files in the given file or directory
+// (recursively).
+func (cl *cleaner) collect(root string) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return fmt.Errorf("Cannot determine path of %s: %w", root, err)
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("Cannot read %s: %w", path, err)
+		}
+		if !d.IsDir() {
+			return cl.collectFile(path)
+		}
+		if cl.isBuildDir(path) {
+			err = cl.collectBuildDir(path)
+			if err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// collectFile collects the given file if it was generated by brief.
+func (cl *cleaner) collectFile(file string) error {
+	if filepath.Base(file) == backend.ManifestName {
+		return nil
+	}
+	// temporary files left behind by interrupted builds
+	if strings.HasPrefix(filepath.Base(file), utils.TempFilePrefix) {
+		cl.add(file)
+		return nil
+	}
+
+	artifacts, err := cl.manifest(backend.BuildDir(cl.cfg, filepath.Dir(file)))
+	if err != nil {
+		return err
+	}
+	for _, artifact := range artifacts {
+		if artifact == file {
+			// keep manually modified TeX files
+			if filepath.Ext(file) == ".tex" && !backend.IsGeneratedTex(file) {
+				return nil
+			}
+			if !cl.keepPdf || !strings.EqualFold(filepath.Ext(file), ".pdf") {
+				cl.add(file)
+			}
+			return nil
+		}
+	}
+
+	if filepath.Ext(file) == ".tex" && backend.IsGeneratedTex(file) {
+		cl.add(file)
+		for _, ext := range intermediateExtensions {
+			if f := utils.DeriveFilePath(file, ext); fileExists(f) {
+				cl.add(f)
+			}
+		}
+	}
+	return nil
+}
+
+// collectBuildDir collects all files in the given build directory except
+// its build manifest, which is updated after cleaning, and the lock files of
+// running builds.
+func (cl *cleaner) collectBuildDir(dir string) error {
+	_, err := cl.manifest(dir)
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("Cannot read %s: %w", path, err)
+		}
+		if !d.IsDir() && path != filepath.Join(dir, backend.ManifestName) && !backend.IsActiveLock(path) {
+			cl.add(path)
+		}
+		return nil
+	})
+}
+
+// isBuildDir returns true if the given directory is a build directory
+// (see Config.BuildDir).
+func (cl *cleaner) isBuildDir(dir string) bool {
+	if cl.cfg.BuildDir == "" {
+		return false
+	}
+	if filepath.IsAbs(cl.cfg.BuildDir) {
+		return dir == filepath.Clean(cl.cfg.BuildDir)
+	}
+	return strings.HasSuffix(dir, string(filepath.Separator)+filepath.Clean(cl.cfg.BuildDir))
+}
+
+// manifest returns the artifacts in the build manifest of the given build
+// directory.
+func (cl *cleaner) manifest(buildDir string) ([]string, error) {
+	if artifacts, ok := cl.manifests[buildDir]; ok {
+		return artifacts, nil
+	}
+	artifacts, err := backend.ReadManifest(buildDir)
+	if err != nil {
+		return nil, err
+	}
+	cl.manifests[buildDir] = artifacts
+	return artifacts, nil
+}
+
+// add collects the given file.
+func (cl *cleaner) add(file string) {
+	if !cl.collected[file] {
+		cl.collected[file] = true
+		cl.files = append(cl.files, file)
+	}
+}
+
+// updateBuildDirs removes the collected files from the build manifests and
+// removes build directories that are empty afterwards.
+func (cl *cleaner) updateBuildDirs() error {
+	buildDirs := make([]string, 0, len(cl.manifests))
+	for buildDir := range cl.manifests {
+		buildDirs = append(buildDirs, buildDir)
+	}
+	sort.Strings(buildDirs)
+
+	for _, buildDir := range buildDirs {
+		remaining := []string{}
+		for _, artifact := range cl.manifests[buildDir] {
+			if !cl.collected[artifact] && fileExists(artifact) {
+				remaining = append(remaining, artifact)
+			}
+		}
+		if !fileExists(buildDir) {
+			continue
+		}
+		err := backend.WriteManifest(buildDir, remaining)
+		if err != nil {
+			return err
+		}
+		if cl.isBuildDir(buildDir) {
+			removeEmptyDirs(buildDir)
+		}
+	}
+	return nil
+}
+
+// removeEmptyDirs removes the given directory and its subdirectories if
+// they are empty.
+func removeEmptyDirs(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			removeEmptyDirs(filepath.Join(dir, entry.Name()))
+		}
+	}
+	// fails if the directory is not empty
+	os.Remove(dir)
+}
+
+// fileExists returns true if the given file exists.
+func fileExists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"poiu.de/brief/backend"
+	"poiu.de/brief/config"
+)
+
+func TestCleaner(t *testing.T) {
+	cases := []struct {
+		keepPdf  bool
+		expected []string
+	}{
+		{false, []string{".brief-build/a.aux", ".brief-build/a.pdf.lock", ".brief-build/a.tex", ".brief-tmp-a.1234.pdf", "a.pdf", "sub/b.aux", "sub/b.tex"}},
+		{true, []string{".brief-build/a.aux", ".brief-build/a.pdf.lock", ".brief-build/a.tex", ".brief-tmp-a.1234.pdf", "sub/b.aux", "sub/b.tex"}},
+	}
+
+	for _, c := range cases {
+		dir := t.TempDir()
+		cfg := &config.Config{BuildDir: ".brief-build"}
+		files := map[string]string{
+			"a.brf":                   ".TEMPLATE\n",
+			"a.pdf":                   "pdf",
+			".brief-build/a.tex":      generatedTex("a"),
+			".brief-build/a.aux":      "aux",
+			"sub/b.tex":               generatedTex("b"),
+			"sub/d.tex":               generatedTex("d") + "% manual change\n",
+			"sub/b.aux":               "aux",
+			"sub/c.tex":               "% written by hand\n",
+			"sub/c.aux":               "aux",
+			"notes.pdf":               "pdf",
+			".brief-tmp-a.1234.pdf":   "pdf",
+			".a.1234.tmp.pdf":         "pdf",
+			".brief-build/a.pdf.lock": "999999999\n",
+			".brief-build/b.pdf.lock": fmt.Sprintln(os.Getpid()),
+		}
+		for name, content := range files {
+			f := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, artifact := range []string{"a.pdf", "sub/d.tex"} {
+			if err := backend.RecordArtifact(cfg, filepath.Join(dir, filepath.FromSlash(artifact))); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		cl := newCleaner(cfg, c.keepPdf)
+		if err := cl.collect(dir); err != nil {
+			t.Fatalf("collect() failed: %v", err)
+		}
+		collected := []string{}
+		for _, f := range cl.files {
+			rel, _ := filepath.Rel(dir, f)
+			collected = append(collected, filepath.ToSlash(rel))
+		}
+		sort.Strings(collected)
+		if !reflect.DeepEqual(collected, c.expected) {
+			t.Errorf("collect(keepPdf=%v) == %q, expected %q", c.keepPdf, collected, c.expected)
+		}
+	}
+}
+
+// generatedTex returns the given TeX code with the header of TeX files
+// generated by brief.
+func generatedTex(content string) string {
+	return fmt.Sprintf("%s from letter.brf (sha1 %x).\n%s", backend.TexMarker, sha1.Sum([]byte(content)), content)
+}
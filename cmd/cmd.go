@@ -1,6 +1,30 @@
 package cmd
 
-import "gopkg.in/alecthomas/kingpin.v2"
+import (
+	"context"
+	"log"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+	"poiu.de/brief/backend"
+	"poiu.de/brief/config"
+)
+
+// Context is the context in which BriefCmds execute external commands.
+//
+// It is cancelled when the application gets interrupted (for example via
+// Ctrl-C), which terminates all running external commands.
+var Context = context.Background()
+
+// recordArtifact adds the given generated file to the build manifest, so
+// that the clean command can remove it.
+//
+// Failing to do so doesn't fail the command and is only logged.
+func recordArtifact(file string, cfg *config.Config) {
+	err := backend.RecordArtifact(cfg, file)
+	if err != nil {
+		log.Println(err)
+	}
+}
 
 // BriefCmd is the base interface for all top level commands of the 'brief'
 // application.
@@ -1,4 +1,95 @@
 package cmd
 
-func Create(args []string) {
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+	"poiu.de/brief/config"
+)
+
+// CreateCommand creates a new .brf file and opens it in the configured
+// editor.
+//
+// The template, sender and recipient can be given on the command line.
+// Otherwise the user can select them.
+type CreateCommand struct {
+	// The .brf file to create.
+	brfFile string
+	// The template, sender and recipient of the new letter.
+	template string
+	from     string
+	to       string
+	// Whether to skip opening the editor.
+	noEdit bool
+	// The configuration for this BriefCmd.
+	Config config.Config
+}
+
+// Configure configures the command line parser for this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.Application.
+func (c *CreateCommand) Configure(app *kingpin.Application) {
+	create := app.Command("create", "Create the given <brfFile> and open it in the editor.").Action(c.run)
+	create.Arg("brfFile", "brf file to create.").Required().StringVar(&c.brfFile)
+	create.Flag("template", "The template to use. If omitted, it can be selected.").Short('t').StringVar(&c.template)
+	create.Flag("from", "The id of the sender. If omitted, it can be selected.").StringVar(&c.from)
+	create.Flag("to", "The id of the recipient. If omitted, it can be selected.").StringVar(&c.to)
+	create.Flag("no-edit", "Don't open the created file in the editor.").BoolVar(&c.noEdit)
+}
+
+// Run executes this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.ParseContext.
+func (c *CreateCommand) run(ctx *kingpin.ParseContext) error {
+	if _, err := os.Stat(c.brfFile); err == nil {
+		return fmt.Errorf("File %s already exists.", c.brfFile)
+	}
+
+	var err error
+	if c.template == "" {
+		c.template, err = selectTemplate(&c.Config)
+		if err != nil {
+			return err
+		}
+	}
+	if c.from == "" {
+		c.from, err = selectAddress(c.Config.SenderList, "Sender", &c.Config)
+		if err != nil {
+			return err
+		}
+	}
+	if c.to == "" && c.Config.AddressBook != "" {
+		if _, statErr := os.Stat(c.Config.AddressBook); statErr == nil {
+			c.to, err = selectAddress(c.Config.AddressBook, "Recipient", &c.Config)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = os.WriteFile(c.brfFile, []byte(c.skeleton()), 0644)
+	if err != nil {
+		return fmt.Errorf("Error writing %s: %w", c.brfFile, err)
+	}
+
+	if c.noEdit {
+		return nil
+	}
+	return openEditor(&c.Config, c.brfFile)
+}
+
+// skeleton returns the content of the new .brf file.
+func (c *CreateCommand) skeleton() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TEMPLATE\n%s\n", c.template)
+	fmt.Fprintf(&b, ".FROM\n%s\n", c.from)
+	fmt.Fprintf(&b, ".TO\n%s\n", c.to)
+	fmt.Fprintf(&b, ".DATE\ntoday\n")
+	fmt.Fprintf(&b, ".SUBJECT\n\n")
+	fmt.Fprintf(&b, ".CONTENT\n\n")
+	return b.String()
 }
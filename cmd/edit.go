@@ -1,4 +1,42 @@
 package cmd
 
-func Edit(args []string) {
+import (
+	"gopkg.in/alecthomas/kingpin.v2"
+	"poiu.de/brief/config"
+)
+
+// EditCommand opens a .brf file in the configured editor.
+//
+// If no .brf file is given, the user can select one of the letters in the
+// document roots.
+type EditCommand struct {
+	// The .brf file to edit.
+	brfFile string
+	// The configuration for this BriefCmd.
+	Config config.Config
+}
+
+// Configure configures the command line parser for this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.Application.
+func (c *EditCommand) Configure(app *kingpin.Application) {
+	edit := app.Command("edit", "Open the given <brfFile> in the editor.").Action(c.run)
+	edit.Arg("brfFile", "brf file to edit. If omitted, a letter can be selected from the document roots.").StringVar(&c.brfFile)
+}
+
+// Run executes this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.ParseContext.
+func (c *EditCommand) run(ctx *kingpin.ParseContext) error {
+	if c.brfFile == "" {
+		brfFile, err := selectLetter(&c.Config)
+		if err != nil {
+			return err
+		}
+		c.brfFile = brfFile
+	}
+
+	return openEditor(&c.Config, c.brfFile)
 }
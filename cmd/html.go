@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"gopkg.in/alecthomas/kingpin.v2"
+	"poiu.de/brief/backend"
+	"poiu.de/brief/config"
+)
+
+// HtmlCommand generates a self-contained HTML file for a .brf file via the
+// html backend.
+type HtmlCommand struct {
+	// The .brf file for which to generate the HTML file.
+	brfFile string
+	// The HTML file (or directory) to write. If empty, the configured
+	// output path is used.
+	output string
+	// The configuration for this BriefCmd.
+	Config config.Config
+}
+
+// Configure configures the command line parser for this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.Application.
+func (c *HtmlCommand) Configure(app *kingpin.Application) {
+	html := app.Command("html", "Convert the given <brfFile> into a html file.").Action(c.run)
+	html.Arg("brfFile", "brf file to convert.").Required().StringVar(&c.brfFile)
+	html.Flag("output", "The html file or directory to write to.").Short('o').StringVar(&c.output)
+}
+
+// Run executes this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.ParseContext.
+func (c *HtmlCommand) run(ctx *kingpin.ParseContext) error {
+	l, err := loadLetter(c.brfFile, nil, &c.Config)
+	if err != nil {
+		return err
+	}
+
+	err = l.Validate()
+	if err != nil {
+		return err
+	}
+
+	htmlFile, err := outputFile(l, "html", c.output, &c.Config)
+	if err != nil {
+		return err
+	}
+	unlock, err := backend.Lock(Context, &c.Config, htmlFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = backend.NewHtml(&c.Config).Render(Context, l, htmlFile)
+	if err != nil {
+		return err
+	}
+	recordArtifact(htmlFile, &c.Config)
+	return nil
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+	"poiu.de/brief/config"
+	"poiu.de/brief/index"
+	"poiu.de/brief/letter"
+	"poiu.de/brief/locale"
+)
+
+// ListCommand lists the letters in the document roots that match some
+// criteria.
+//
+// It utilizes an index of all .brf files that is updated incrementally
+// before each query.
+type ListCommand struct {
+	// The criteria for the letters to list.
+	to    string
+	from  string
+	since string
+	until string
+	tags  []string
+	// The output format. One of table, json or lister.
+	format string
+	// Whether to skip updating the index before querying it.
+	noUpdate bool
+	// The configuration for this BriefCmd.
+	Config config.Config
+}
+
+// Configure configures the command line parser for this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.Application.
+func (c *ListCommand) Configure(app *kingpin.Application) {
+	list := app.Command("list", "List the letters in the document roots.").Action(c.run)
+	list.Flag("to", "Only list letters to recipients containing this text.").StringVar(&c.to)
+	list.Flag("from", "Only list letters from senders containing this text.").StringVar(&c.from)
+	list.Flag("since", "Only list letters dated on or after this date.").StringVar(&c.since)
+	list.Flag("until", "Only list letters dated on or before this date.").StringVar(&c.until)
+	list.Flag("tag", "Only list letters with this tag. May be given multiple times.").StringsVar(&c.tags)
+	list.Flag("format", "The output format.").Short('f').Default("table").EnumVar(&c.format, "table", "json", "lister")
+	list.Flag("no-update", "Don't update the index before listing.").BoolVar(&c.noUpdate)
+}
+
+// Run executes this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.ParseContext.
+func (c *ListCommand) run(ctx *kingpin.ParseContext) error {
+	q, err := c.query()
+	if err != nil {
+		return err
+	}
+
+	idx, err := loadIndex(&c.Config, !c.noUpdate)
+	if err != nil {
+		return err
+	}
+
+	entries := idx.Find(q)
+	switch c.format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case "lister":
+		for _, e := range entries {
+			fmt.Printf("%s\t%s\t%s\t%s\n", e.File, e.Date, e.To, e.Subject)
+		}
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DATE\tTO\tSUBJECT\tTAGS\tPDF\tFILE")
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Date, e.To, e.Subject, strings.Join(e.Tags, ","), e.Status, e.File)
+		}
+		return w.Flush()
+	}
+
+	return nil
+}
+
+// query creates the index.Query for the criteria given on the command line.
+//
+// The dates may be given in any format supported for the DATE section of a
+// .brf file, like "2020-01-01" or "-1m".
+func (c *ListCommand) query() (index.Query, error) {
+	q := index.Query{To: c.to, From: c.from, Tags: c.tags}
+
+	loc, err := locale.Get(c.Config.Language)
+	if err != nil {
+		return q, err
+	}
+	if c.since != "" {
+		since, err := letter.ParseDate(c.since, letter.Now(), loc)
+		if err != nil {
+			return q, fmt.Errorf("Invalid --since: %w", err)
+		}
+		q.Since = since.Format("2006-01-02")
+	}
+	if c.until != "" {
+		until, err := letter.ParseDate(c.until, letter.Now(), loc)
+		if err != nil {
+			return q, fmt.Errorf("Invalid --until: %w", err)
+		}
+		q.Until = until.Format("2006-01-02")
+	}
+
+	return q, nil
+}
+
+// loadIndex loads the index of all .brf files in the configured document
+// roots.
+//
+// If update is true, the index is updated and saved before it is returned.
+func loadIndex(cfg *config.Config, update bool) (*index.Index, error) {
+	if cfg.IndexFile == "" {
+		return nil, fmt.Errorf("No index file configured.")
+	}
+
+	idx, err := index.Load(cfg.IndexFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if update {
+		err = idx.Update(cfg.DocumentRoots, cfg)
+		if err != nil {
+			return nil, err
+		}
+		err = idx.Save(cfg.IndexFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return idx, nil
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+	"poiu.de/brief/backend"
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+	"poiu.de/brief/mailer"
+	"poiu.de/brief/parser"
+	"poiu.de/brief/utils"
+)
+
+// MailCommand sends the PDF file of a .brf file by email.
+//
+// The PDF file is generated first if it is missing or older than the .brf
+// file. The body of the email is taken from the EMAILBODY section of the
+// .brf file or, if that is missing, from the plain text rendering of the
+// letter.
+type MailCommand struct {
+	// The .brf file to send.
+	brfFile string
+	// The email address to send to instead of the one of the recipient.
+	to string
+	// Whether to only write the email to an .eml file instead of sending it.
+	dryRun bool
+	// The configuration for this BriefCmd.
+	Config config.Config
+}
+
+// Configure configures the command line parser for this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.Application.
+func (c *MailCommand) Configure(app *kingpin.Application) {
+	mail := app.Command("mail", "Send the pdf file of the given <brfFile> by email.").Action(c.run)
+	mail.Arg("brfFile", "brf file to send.").Required().StringVar(&c.brfFile)
+	mail.Flag("to", "Send to this email address instead of the one of the recipient.").StringVar(&c.to)
+	mail.Flag("dry-run", "Don't send the email, but write it to an .eml file.").BoolVar(&c.dryRun)
+	mail.Flag("smtp-host", "The SMTP server to use.").Default(c.Config.SmtpHost).StringVar(&c.Config.SmtpHost)
+	mail.Flag("smtp-port", "The port of the SMTP server.").Default(fmt.Sprint(c.Config.SmtpPort)).IntVar(&c.Config.SmtpPort)
+	mail.Flag("starttls", "Require STARTTLS.").Default(fmt.Sprint(c.Config.SmtpStartTLS)).BoolVar(&c.Config.SmtpStartTLS)
+}
+
+// Run executes this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.ParseContext.
+func (c *MailCommand) run(ctx *kingpin.ParseContext) error {
+	l, err := loadLetter(c.brfFile, nil, &c.Config)
+	if err != nil {
+		return err
+	}
+
+	m, err := c.message(l)
+	if err != nil {
+		return err
+	}
+
+	pdfFile, err := outputFile(l, "pdf", "", &c.Config)
+	if err != nil {
+		return err
+	}
+	err = renderPdf(l, "", pdfFile, false, &c.Config)
+	if err != nil {
+		return err
+	}
+	pdf, err := os.ReadFile(pdfFile)
+	if err != nil {
+		return fmt.Errorf("Cannot read pdf file %s: %w", pdfFile, err)
+	}
+	m.Attachments = []mailer.Attachment{{Name: filepath.Base(pdfFile), ContentType: "application/pdf", Data: pdf}}
+
+	if c.dryRun {
+		data, err := m.Bytes()
+		if err != nil {
+			return fmt.Errorf("Error composing email for %s: %w", c.brfFile, err)
+		}
+		emlFile, err := outputFile(l, "eml", "", &c.Config)
+		if err != nil {
+			return err
+		}
+		err = utils.WriteFileAtomic(emlFile, data)
+		if err != nil {
+			return fmt.Errorf("Could not write email to %s: %w", emlFile, err)
+		}
+		recordArtifact(emlFile, &c.Config)
+		fmt.Printf("Email to %s written to %s\n", strings.Join(m.Recipients(), ", "), emlFile)
+		return nil
+	}
+
+	return mailer.Send(mailer.SmtpConfig{
+		Host:     c.Config.SmtpHost,
+		Port:     c.Config.SmtpPort,
+		Username: c.Config.SmtpUser,
+		Password: c.Config.SmtpPassword,
+		StartTLS: c.Config.SmtpStartTLS,
+	}, m)
+}
+
+// message creates the email for the given Letter (without the attachment).
+//
+// The sender and recipient addresses are taken from the 'email' fields of
+// the sender and recipient.
+func (c *MailCommand) message(l *letter.Letter) (mailer.Message, error) {
+	from := l.Sender.Value("email")
+	if from == "" {
+		return mailer.Message{}, fmt.Errorf("Sender %s has no email address", l.Sender.Value("fromName"))
+	}
+
+	to := c.to
+	if to == "" {
+		to = l.Recipient.Value("email")
+	}
+	if to == "" {
+		return mailer.Message{}, fmt.Errorf("Recipient %s has no email address. Use --to to specify one.", l.Recipient.Value("name"))
+	}
+
+	body := parser.TrimSurroundingEmptyLines(l.Brf.Sections["EMAILBODY"])
+	var bodyText string
+	if len(body) > 0 {
+		bodyText = strings.Join(body, "\n") + "\n"
+	} else {
+		b := backend.NewText(&c.Config)
+		b.Email = true
+		bodyText = b.RenderText(Context, l)
+	}
+
+	return mailer.Message{
+		From:    mail.Address{Name: l.Sender.Value("fromName"), Address: from},
+		To:      []mail.Address{{Name: l.Recipient.Value("name"), Address: to}},
+		Subject: strings.Join(parser.TrimSurroundingEmptyLines(l.Brf.Sections["SUBJECT"]), " "),
+		Body:    bodyText,
+		Date:    letter.Now(),
+	}, nil
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+	"poiu.de/brief/address"
+	"poiu.de/brief/backend"
+	"poiu.de/brief/cmdline"
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+	"poiu.de/brief/utils"
+)
+
+// MergeCommand generates serial letters by rendering a single .brf file
+// once for each record of a data source.
+//
+// The fields of each record are used as the recipient of the letter and
+// can be referred to by placeholders like ${to.name} in the .brf file.
+type MergeCommand struct {
+	// The .brf file to use for all letters.
+	brfFile string
+	// The CSV or JSON file with one record per recipient.
+	dataFile string
+	// The address book group to use as recipients.
+	group string
+	// The pattern for the file names of the generated PDF files.
+	pattern string
+	// The PDF file to join all generated letters into.
+	combined string
+	// The number of letters to generate in parallel.
+	jobs int
+	// The backend to use. If empty, the backend of the letter is used.
+	backend string
+	// The configuration for this BriefCmd.
+	Config config.Config
+}
+
+// mergeResult is the outcome of generating the letter for a single record.
+type mergeResult struct {
+	// The number of the record (starting at 1).
+	row int
+	// The generated PDF file.
+	pdfFile string
+	// The error that occurred while generating the PDF file, if any.
+	err error
+}
+
+// Configure configures the command line parser for this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.Application.
+func (c *MergeCommand) Configure(app *kingpin.Application) {
+	merge := app.Command("merge", "Generate one pdf file per record in a data source from the given <brfFile> (serial letters).").Action(c.run)
+	merge.Arg("brfFile", "brf file to use for all letters.").Required().StringVar(&c.brfFile)
+	merge.Flag("data", "CSV or JSON file with one record per recipient.").Short('d').StringVar(&c.dataFile)
+	merge.Flag("group", "Send the letter to all members of this address book group.").Short('g').StringVar(&c.group)
+	merge.Flag("pattern", "Pattern for the names of the generated pdf files. Supports {name}, {n} and {to.<field>}.").Short('p').Default("{name}-{n}").StringVar(&c.pattern)
+	merge.Flag("combined", "Join all letters into this single pdf file instead.").Short('c').StringVar(&c.combined)
+	merge.Flag("jobs", "Number of letters to generate in parallel.").Short('j').Default(fmt.Sprint(runtime.NumCPU())).IntVar(&c.jobs)
+	merge.Flag("backend", "The backend to use ("+strings.Join(backend.Names(), ", ")+").").Short('b').StringVar(&c.backend)
+}
+
+// Run executes this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.ParseContext.
+func (c *MergeCommand) run(ctx *kingpin.ParseContext) error {
+	if (c.dataFile == "") == (c.group == "") {
+		return fmt.Errorf("Exactly one of --data or --group must be given.")
+	}
+	if c.combined != "" && c.Config.PdfJoinCommand == "" {
+		return fmt.Errorf("No pdf join command configured. Cannot produce combined pdf file.")
+	}
+	if c.jobs < 1 {
+		c.jobs = 1
+	}
+
+	var recipients []address.Address
+	var err error
+	if c.dataFile != "" {
+		recipients, err = address.ReadRecords(c.dataFile)
+	} else {
+		recipients, err = address.ReadGroup(c.Config.AddressBook, c.group)
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot read recipients: %w", err)
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("No recipients found.")
+	}
+
+	baseNames := make([]string, len(recipients))
+	rowsByName := make(map[string]int)
+	for i, recipient := range recipients {
+		baseNames[i], err = c.fileName(i+1, recipient)
+		if err != nil {
+			return fmt.Errorf("Invalid file name pattern: %w", err)
+		}
+		if baseNames[i] == "" {
+			return fmt.Errorf("File name pattern %s results in an empty file name for row %d", c.pattern, i+1)
+		}
+		if otherRow, exists := rowsByName[baseNames[i]]; exists {
+			return fmt.Errorf("File name pattern %s results in the same file name %s for row %d and %d", c.pattern, baseNames[i], otherRow, i+1)
+		}
+		rowsByName[baseNames[i]] = i + 1
+	}
+
+	outputDir := letter.OutputDir(c.brfFile, &c.Config)
+	err = os.MkdirAll(outputDir, 0755)
+	if err != nil {
+		return fmt.Errorf("Cannot create output directory %s: %w", outputDir, err)
+	}
+
+	results := c.generateAll(recipients, baseNames)
+
+	failed := make([]mergeResult, 0)
+	pdfFiles := make([]string, 0, len(results))
+	for _, r := range results {
+		if r.err != nil {
+			failed = append(failed, r)
+		} else {
+			pdfFiles = append(pdfFiles, r.pdfFile)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Generated %d of %d letters.\n", len(pdfFiles), len(results))
+	for _, r := range failed {
+		fmt.Fprintf(os.Stderr, "  row %d (%s): %v\n", r.row, recipients[r.row-1].Value("name"), r.err)
+	}
+
+	if c.combined != "" && len(pdfFiles) > 0 {
+		err := c.join(pdfFiles)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Generating %d of %d letters failed.", len(failed), len(results))
+	}
+
+	return nil
+}
+
+// generateAll generates the letters for all given recipients in parallel.
+// The generated files are named after the corresponding baseNames.
+//
+// The returned mergeResults are in the same order as the recipients.
+func (c *MergeCommand) generateAll(recipients []address.Address, baseNames []string) []mergeResult {
+	results := make([]mergeResult, len(recipients))
+	rows := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < c.jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range rows {
+				pdfFile, err := c.generate(recipients[i], baseNames[i])
+				results[i] = mergeResult{row: i + 1, pdfFile: pdfFile, err: err}
+			}
+		}()
+	}
+
+	for i := range recipients {
+		rows <- i
+	}
+	close(rows)
+	wg.Wait()
+
+	return results
+}
+
+// generate generates the PDF file of the letter for a single recipient.
+//
+// The generated files are written into the output directory of the brfFile
+// (see letter.OutputDir) and are named after the given baseName.
+// The path of the generated PDF file is returned.
+func (c *MergeCommand) generate(recipient address.Address, baseName string) (string, error) {
+	l, err := loadLetter(c.brfFile, &recipient, &c.Config)
+	if err != nil {
+		return "", err
+	}
+
+	pdfFile := filepath.Join(letter.OutputDir(c.brfFile, &c.Config), baseName+".pdf")
+	err = renderPdf(l, c.backend, pdfFile, true, &c.Config)
+	if err != nil {
+		return "", err
+	}
+
+	return pdfFile, nil
+}
+
+// fileName returns the file name (without extension) of the letter for the
+// given recipient by replacing the placeholders in the file name pattern
+// (see letter.ExpandPattern).
+//
+// If a combined PDF file is requested, the pattern is ignored and a name
+// derived from the combined PDF file is used instead, as those files are
+// only temporary.
+func (c *MergeCommand) fileName(row int, recipient address.Address) (string, error) {
+	if c.combined != "" {
+		return fmt.Sprintf("%s-%04d", utils.SanitizeFileName(strings.TrimSuffix(filepath.Base(c.combined), filepath.Ext(c.combined))), row), nil
+	}
+
+	values := map[string]string{
+		"n":    fmt.Sprintf("%04d", row),
+		"name": strings.TrimSuffix(filepath.Base(c.brfFile), filepath.Ext(c.brfFile)),
+	}
+	for field := range recipient.Fields {
+		values["to."+field] = recipient.Value(field)
+	}
+	return letter.ExpandPattern(c.pattern, values)
+}
+
+// join joins the given PDF files into the combined PDF file and removes
+// the given PDF files and their intermediate files in the build directory
+// afterwards.
+func (c *MergeCommand) join(pdfFiles []string) error {
+	args := make([]string, 0, len(pdfFiles)+1)
+	for _, f := range pdfFiles {
+		args = append(args, cmdline.Quote(f))
+	}
+	// the combined pdf file is only replaced once it is complete
+	tmpFile, err := utils.TempFileFor(c.combined)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile)
+	args = append(args, cmdline.Quote(tmpFile))
+
+	cmdCtx, cancel := c.Config.CommandContext(Context, config.TimeoutPdfJoin)
+	defer cancel()
+	cmdLine := c.Config.PdfJoinCommand + " " + strings.Join(args, " ")
+	stdout := &strings.Builder{}
+	stderr := &strings.Builder{}
+	err = cmdline.ExecuteContext(cmdCtx, cmdLine, "", nil, stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("Error joining pdf files into %s: %w", c.combined, err)
+	}
+	err = utils.CommitTempFile(tmpFile, c.combined)
+	if err != nil {
+		return err
+	}
+	recordArtifact(c.combined, &c.Config)
+
+	for _, f := range pdfFiles {
+		removeFile(f)
+		base := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+		intermediates, _ := filepath.Glob(filepath.Join(backend.BuildDir(&c.Config, filepath.Dir(f)), base+".*"))
+		for _, intermediate := range intermediates {
+			removeFile(intermediate)
+		}
+	}
+
+	return nil
+}
+
+// removeFile removes the given temporary file.
+//
+// Failing to do so doesn't fail the command and is only logged.
+func removeFile(file string) {
+	err := os.Remove(file)
+	if err != nil && !os.IsNotExist(err) {
+		log.Println(fmt.Errorf("Cannot remove %s: %w", file, err))
+	}
+}
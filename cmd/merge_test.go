@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"poiu.de/brief/address"
+	"poiu.de/brief/config"
+	"poiu.de/brief/parser"
+)
+
+func TestMergeFileName(t *testing.T) {
+	recipient := address.Address{Fields: map[string]parser.BrfLines{"name": {"Jane Doe"}, "city": {"Berlin"}}}
+
+	cases := []struct {
+		pattern  string
+		combined string
+		expected string
+		problem  string
+	}{
+		{"{name}-{n}", "", "notice-0007", ""},
+		{"{to.name}_{to.city}", "", "Jane_Doe_Berlin", ""},
+		{"{name}-{to.email}", "", "", "Unknown placeholder {to.email}"},
+		{"{date}", "", "", "Unknown placeholder {date}"},
+		{"{to.name}", "out/all tenants.pdf", "all_tenants-0007", ""},
+	}
+
+	for _, c := range cases {
+		cmd := &MergeCommand{brfFile: filepath.Join("letters", "notice.brf"), pattern: c.pattern, combined: c.combined}
+		name, err := cmd.fileName(7, recipient)
+		if c.problem != "" {
+			if err == nil || !strings.Contains(err.Error(), c.problem) {
+				t.Errorf("fileName() for %q == %q, %v, expected error containing %q", c.pattern, name, err, c.problem)
+			}
+			continue
+		}
+		if err != nil || name != c.expected {
+			t.Errorf("fileName() for %q == %q, %v, expected %q", c.pattern, name, err, c.expected)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "letters")
+	outputDir := filepath.Join(dir, "out")
+	files := map[string]string{
+		"senders":                 "address: me\nname: Max Mustermann\n",
+		"tenants.csv":             "name,city\nAnna,Berlin\nFail,Hamburg\nOtto,Köln\n",
+		"letters/2025/notice.brf": ".FROM\nme\n.SUBJECT\nNotice for ${to.name}\n.CONTENT\nHello\n",
+		"bin/typst":               "#!/bin/sh\n# fails for the recipient Fail\ngrep -q Fail \"$1\" && exit 1\necho \"pdf of $1\" > \"$2\"\n",
+		"bin/join":                "#!/bin/sh\nfor last; do :; done\nwhile [ $# -gt 1 ]; do cat \"$1\"; shift; done > \"$last\"\n",
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := config.Config{
+		SenderList:     filepath.Join(dir, "senders"),
+		DocumentRoots:  []string{root},
+		OutputDir:      outputDir,
+		BuildDir:       ".brief-build",
+		Language:       "en",
+		TypstCommand:   filepath.Join(dir, "bin", "typst"),
+		PdfJoinCommand: filepath.Join(dir, "bin", "join"),
+	}
+	brfFile := filepath.Join(root, "2025", "notice.brf")
+
+	// a failing row doesn't prevent the other letters, which are written
+	// into the output directory
+	cmd := &MergeCommand{brfFile: brfFile, dataFile: filepath.Join(dir, "tenants.csv"), pattern: "{to.name}-{n}", jobs: 2, backend: "typst", Config: cfg}
+	err := cmd.run(nil)
+	if err == nil || !strings.Contains(err.Error(), "Generating 1 of 3 letters failed") {
+		t.Errorf("run() == %v, expected failure of one letter", err)
+	}
+	for name, exists := range map[string]bool{"Anna-0001.pdf": true, "Fail-0002.pdf": false, "Otto-0003.pdf": true} {
+		_, err := os.Stat(filepath.Join(outputDir, "2025", name))
+		if (err == nil) != exists {
+			t.Errorf("Existence of %s == %t, expected %t", name, err == nil, exists)
+		}
+	}
+
+	// the successful letters are joined into the combined pdf file and
+	// removed afterwards
+	combined := filepath.Join(dir, "all.pdf")
+	cmd = &MergeCommand{brfFile: brfFile, dataFile: filepath.Join(dir, "tenants.csv"), combined: combined, jobs: 2, backend: "typst", Config: cfg}
+	err = cmd.run(nil)
+	if err == nil {
+		t.Errorf("run() with combined pdf file succeeded despite failing row")
+	}
+	content, err := os.ReadFile(combined)
+	if err != nil {
+		t.Fatalf("Combined pdf file not written: %v", err)
+	}
+	if !strings.Contains(string(content), "all-0001.typ") || !strings.Contains(string(content), "all-0003.typ") || strings.Contains(string(content), "all-0002") {
+		t.Errorf("Combined pdf file contains %q, expected letters 1 and 3", content)
+	}
+	if remaining, _ := filepath.Glob(filepath.Join(outputDir, "2025", "all-*.pdf")); len(remaining) > 0 {
+		t.Errorf("Per-record pdf files %v were not removed", remaining)
+	}
+}
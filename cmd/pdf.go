@@ -7,19 +7,27 @@ import (
 	"strings"
 
 	"gopkg.in/alecthomas/kingpin.v2"
-	"poiu.de/brief/cmdline"
+	"poiu.de/brief/backend"
 	"poiu.de/brief/config"
-	"poiu.de/brief/utils"
+	"poiu.de/brief/letter"
 )
 
-// PdfCommand calls an external application to generate the PDF file for a
-// .brf file.
+// PdfCommand generates the PDF file for a .brf file.
 //
-// If necessary it creates the corresponding TeX file by calling the
-// TexCommand.
+// The PDF file is generated by the backend given on the command line, in
+// the BACKEND section of the .brf file or the default backend (in this
+// order).
 type PdfCommand struct {
 	// The .brf file for which to generate the PDF.
 	brfFile string
+	// The backend to use. If empty, the backend of the letter is used.
+	backend string
+	// Whether to generate the PDF file from the existing TeX file of the
+	// .brf file instead of the .brf file itself.
+	fromTex bool
+	// The PDF file (or directory) to write. If empty, the configured output
+	// path is used.
+	output string
 	// The configuration for this BriefCmd.
 	Config config.Config
 }
@@ -29,8 +37,11 @@ type PdfCommand struct {
 // kingpin is used for commandline parsing and therefore the only
 // accepted parameter is a pointer to a kingpin.Application.
 func (c *PdfCommand) Configure(app *kingpin.Application) {
-	pdf := app.Command("pdf", "Convert the given <brfFile> into a pdf file (via tex).").Action(c.run)
+	pdf := app.Command("pdf", "Convert the given <brfFile> into a pdf file.").Action(c.run)
 	pdf.Arg("brfFile", "brf file to convert.").Required().StringVar(&c.brfFile)
+	pdf.Flag("backend", "The backend to use ("+strings.Join(backend.Names(), ", ")+").").Short('b').StringVar(&c.backend)
+	pdf.Flag("output", "The pdf file or directory to write to.").Short('o').StringVar(&c.output)
+	pdf.Flag("from-tex", "Generate the pdf file from the existing (maybe manually edited) tex file of the <brfFile>.").BoolVar(&c.fromTex)
 }
 
 // Run executes this BriefCmd
@@ -38,58 +49,118 @@ func (c *PdfCommand) Configure(app *kingpin.Application) {
 // kingpin is used for commandline parsing and therefore the only
 // accepted parameter is a pointer to a kingpin.ParseContext.
 func (c *PdfCommand) run(ctx *kingpin.ParseContext) error {
-	if c.Config.PdfCommand == "" {
-		return fmt.Errorf("No pdf command configured. Cannot produce pdf file.")
+	l, err := loadLetter(c.brfFile, nil, &c.Config)
+	if err != nil {
+		return err
 	}
 
-	texFile := utils.DeriveFilePath(c.brfFile, "tex")
-
-	// create the TeX file first, if necessary
-	needsTexRun := !IsNewerThan(texFile, c.brfFile)
-	if needsTexRun {
-		//FIXME: We already create a TexCommand in the main method.
-		//       We should reuse that one.
-		texCmd := &TexCommand{brfFile: c.brfFile, Config: c.Config}
-		err := texCmd.run(nil)
+	pdfFile, err := outputFile(l, "pdf", c.output, &c.Config)
+	if err != nil {
+		return err
+	}
+	if c.fromTex {
+		if c.backend != "" && c.backend != "latex" {
+			return fmt.Errorf("Backend %s cannot generate pdf files from tex files.", c.backend)
+		}
+		texFile, err := l.OutputFile("tex", &c.Config)
 		if err != nil {
-			return fmt.Errorf("Cannot create tex file for %s: %w", c.brfFile, err)
+			return err
 		}
+		return renderPdfFromTex(l, texFile, pdfFile, &c.Config)
 	}
 
-	// now execute the command to generate the pdf
-	cmdLine := c.Config.PdfCommand + " " + texFile
-	stdout := &strings.Builder{}
-	stderr := &strings.Builder{}
-	err := cmdline.Execute(cmdLine, filepath.Dir(c.brfFile), nil, stdout, stderr)
+	return renderPdf(l, c.backend, pdfFile, true, &c.Config)
+}
+
+// outputFile returns the path of the artifact with the given extension for
+// the given Letter (see letter.OutputFile) and creates its directory.
+//
+// If an output is given (via --output), it is used instead. If it is a
+// directory, the artifact is written into it.
+func outputFile(l *letter.Letter, ext string, output string, cfg *config.Config) (string, error) {
+	file, err := l.OutputFile(ext, cfg)
 	if err != nil {
-		return fmt.Errorf("Error generating pdf file for %s via %s, %w", c.brfFile, cmdLine, err)
+		return "", err
+	}
+	if output != "" {
+		info, err := os.Stat(output)
+		if (err == nil && info.IsDir()) || strings.HasSuffix(output, "/") || strings.HasSuffix(output, string(filepath.Separator)) {
+			file = filepath.Join(output, filepath.Base(file))
+		} else {
+			file = output
+		}
 	}
 
-	return nil
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return "", fmt.Errorf("Cannot create output directory %s: %w", filepath.Dir(file), err)
+	}
+	return file, nil
 }
 
-// IsNewerThan check whether file1 is newer than file2.
+// renderPdf generates the given PDF file for the given Letter.
+//
+// The backend with the given name is used or, if the name is empty, the
+// backend of the letter. An error is returned if that backend doesn't
+// produce PDF files.
 //
-// If file2 does not exists, 'true' is returned.
-// If file1 does not exist, but file2 does, 'false' is returned.
-// If both files don't exist, true is returned.
-// Otherwise this returns 'true' if the modification time of file1 is newer
-// than the modification time of file2.
-func IsNewerThan(file1, file2 string) bool {
-	//TODO: We check here only for errors, not specific NotExistError.
-	//      Should we change that? What should happen on other errors?
-	info2, err := os.Stat(file2)
+// Unless force is true, the PDF file is only generated if it is stale.
+func renderPdf(l *letter.Letter, backendName string, pdfFile string, force bool, cfg *config.Config) error {
+	b, err := backend.ForLetter(l, backendName, cfg)
+	if err != nil {
+		return err
+	}
+	// check the letter before running any external command
+	err = backend.Validate(b, l)
 	if err != nil {
-		return true
+		return err
 	}
+	if b.Extension() != "pdf" {
+		return fmt.Errorf("Backend %s does not produce pdf files.", b.Name())
+	}
+
+	unlock, err := backend.Lock(Context, cfg, pdfFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if !force && !backend.IsStale(b, l, pdfFile) {
+		return nil
+	}
+
+	err = b.Render(Context, l, pdfFile)
+	if err != nil {
+		return err
+	}
+	recordArtifact(pdfFile, cfg)
+	reportRendering(b, pdfFile)
+	return nil
+}
 
-	info1, err := os.Stat(file1)
+// renderPdfFromTex generates the given PDF file for the given Letter from
+// the given TeX file instead of generating the TeX file from the Letter.
+func renderPdfFromTex(l *letter.Letter, texFile string, pdfFile string, cfg *config.Config) error {
+	unlock, err := backend.Lock(Context, cfg, pdfFile)
 	if err != nil {
-		return false
+		return err
 	}
+	defer unlock()
 
-	mTime1 := info1.ModTime()
-	mTime2 := info2.ModTime()
+	b := backend.NewLatex(cfg)
+	err = b.RenderTex(Context, l, texFile, pdfFile)
+	if err != nil {
+		return err
+	}
+	recordArtifact(pdfFile, cfg)
+	reportRendering(b, pdfFile)
+	return nil
+}
 
-	return mTime1.After(mTime2)
+// reportRendering prints how the given Backend rendered the given file, if
+// it can tell (see backend.Reporter).
+func reportRendering(b backend.Backend, outFile string) {
+	if r, ok := b.(backend.Reporter); ok && r.Report() != "" {
+		fmt.Printf("Generated %s via %s\n", outFile, r.Report())
+	}
 }
@@ -2,22 +2,28 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"gopkg.in/alecthomas/kingpin.v2"
+	"poiu.de/brief/backend"
 	"poiu.de/brief/cmdline"
 	"poiu.de/brief/config"
-	"poiu.de/brief/utils"
 )
 
 // PreviewCommand calls an external application to display the PDF file
 // generated from a .brf file.
 //
-// If necessary it creates the corresponding PDF file by calling the
-// PdfCommand (which itself may call the TexCommand if necessary).
+// If necessary it generates the corresponding PDF file first.
+//
+// The configured PreviewCommand may refer to the PDF file via the
+// placeholders of cmdline.FilePlaceholders. If it contains neither %f nor
+// %b, the PDF file is appended to it.
 type PreviewCommand struct {
 	// The .brf file to preview.
 	brfFile string
+	// The backend to use. If empty, the backend of the letter is used.
+	backend string
 	// The configuration for this BriefCmd.
 	Config config.Config
 }
@@ -27,8 +33,9 @@ type PreviewCommand struct {
 // kingpin is used for commandline parsing and therefore the only accepted
 // parameter is a pointer to a kingpin.Application.
 func (c *PreviewCommand) Configure(app *kingpin.Application) {
-	preview := app.Command("preview", "Convert the given <brfFile> into a preview file (via tex).").Action(c.run)
+	preview := app.Command("preview", "Convert the given <brfFile> into a pdf file and display it.").Action(c.run)
 	preview.Arg("brfFile", "brf file to convert.").Required().StringVar(&c.brfFile)
+	preview.Flag("backend", "The backend to use ("+strings.Join(backend.Names(), ", ")+").").Short('b').StringVar(&c.backend)
 }
 
 // Run executes this BriefCmd
@@ -40,27 +47,31 @@ func (c *PreviewCommand) run(ctx *kingpin.ParseContext) error {
 		return fmt.Errorf("No preview command configured. Cannot open preview.")
 	}
 
-	pdfFile := utils.DeriveFilePath(c.brfFile, "pdf")
+	l, err := loadLetter(c.brfFile, nil, &c.Config)
+	if err != nil {
+		return err
+	}
 
 	// create the PDF file first, if necessary
-	needsPdfRun := !IsNewerThan(pdfFile, c.brfFile)
-	if needsPdfRun {
-		//FIXME: We already create a PdfCommand in the main method.
-		//       We should reuse that one.
-		pdfCmd := &PdfCommand{brfFile: c.brfFile, Config: c.Config}
-		err := pdfCmd.run(nil)
-		if err != nil {
-			return fmt.Errorf("Cannot create pdf file for %s: %w", c.brfFile, err)
-		}
+	pdfFile, err := outputFile(l, "pdf", "", &c.Config)
+	if err != nil {
+		return err
+	}
+	err = renderPdf(l, c.backend, pdfFile, false, &c.Config)
+	if err != nil {
+		return fmt.Errorf("Cannot create pdf file for %s: %w", c.brfFile, err)
 	}
 
 	// execute the Previewer
-	cmdLine := c.Config.PreviewCommand + " " + pdfFile
+	cmdCtx, cancel := c.Config.CommandContext(Context, config.TimeoutPreview)
+	defer cancel()
+	cmdLine := cmdline.WithFile(c.Config.PreviewCommand)
+	placeholders := cmdline.FilePlaceholders(pdfFile, filepath.Dir(pdfFile))
 	stdout := &strings.Builder{}
 	stderr := &strings.Builder{}
-	err := cmdline.Execute(cmdLine, "", nil, stdout, stderr)
+	err = cmdline.ExecuteWithPlaceholders(cmdCtx, cmdLine, placeholders, "", nil, stdout, stderr)
 	if err != nil {
-		return fmt.Errorf("Error opening previewer for %s via %s, %w", c.brfFile, cmdLine, err)
+		return fmt.Errorf("Error opening previewer for %s: %w", c.brfFile, err)
 	}
 
 	return nil
@@ -1,4 +1,71 @@
 package cmd
 
-func Print(args []string) {
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+	"poiu.de/brief/backend"
+	"poiu.de/brief/cmdline"
+	"poiu.de/brief/config"
+)
+
+// PrintCommand calls an external application to print the PDF file
+// generated from a .brf file.
+//
+// If necessary it generates the corresponding PDF file first.
+type PrintCommand struct {
+	// The .brf file to print.
+	brfFile string
+	// The backend to use. If empty, the backend of the letter is used.
+	backend string
+	// The configuration for this BriefCmd.
+	Config config.Config
+}
+
+// Configure configures the command line parser for this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.Application.
+func (c *PrintCommand) Configure(app *kingpin.Application) {
+	printCmd := app.Command("print", "Convert the given <brfFile> into a pdf file and print it.").Action(c.run)
+	printCmd.Arg("brfFile", "brf file to print.").Required().StringVar(&c.brfFile)
+	printCmd.Flag("backend", "The backend to use ("+strings.Join(backend.Names(), ", ")+").").Short('b').StringVar(&c.backend)
+}
+
+// Run executes this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.ParseContext.
+func (c *PrintCommand) run(ctx *kingpin.ParseContext) error {
+	if c.Config.PrintCommand == "" {
+		return fmt.Errorf("No print command configured. Cannot print.")
+	}
+
+	l, err := loadLetter(c.brfFile, nil, &c.Config)
+	if err != nil {
+		return err
+	}
+
+	// create the PDF file first, if necessary
+	pdfFile, err := outputFile(l, "pdf", "", &c.Config)
+	if err != nil {
+		return err
+	}
+	err = renderPdf(l, c.backend, pdfFile, false, &c.Config)
+	if err != nil {
+		return fmt.Errorf("Cannot create pdf file for %s: %w", c.brfFile, err)
+	}
+
+	cmdCtx, cancel := c.Config.CommandContext(Context, config.TimeoutPrint)
+	defer cancel()
+	cmdLine := c.Config.PrintCommand + " " + cmdline.Quote(pdfFile)
+	stdout := &strings.Builder{}
+	stderr := &strings.Builder{}
+	err = cmdline.ExecuteContext(cmdCtx, cmdLine, "", nil, stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("Error printing %s: %w", pdfFile, err)
+	}
+
+	return nil
 }
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+	"poiu.de/brief/cmdline"
+	"poiu.de/brief/config"
+	"poiu.de/brief/search"
+)
+
+// SearchCommand searches the content of all .brf files in the document
+// roots.
+//
+// Simple queries are delegated to the configured FindCommand. All other
+// queries (and all queries if no FindCommand is configured) are handled
+// by the built-in search.
+type SearchCommand struct {
+	// The words of the query.
+	query []string
+	// Whether to use the built-in search even if a FindCommand is
+	// configured.
+	builtin bool
+	// Whether to use the on-disk search index.
+	useIndex bool
+	// The configuration for this BriefCmd.
+	Config config.Config
+}
+
+// Configure configures the command line parser for this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.Application.
+func (c *SearchCommand) Configure(app *kingpin.Application) {
+	s := app.Command("search", "Search the content of all letters. Supports \"phrases\" and field:term (like subject:tax).").Action(c.run)
+	s.Arg("query", "The search query.").Required().StringsVar(&c.query)
+	s.Flag("builtin", "Always use the built-in search.").BoolVar(&c.builtin)
+	s.Flag("index", "Use (and update) the on-disk search index.").Default(fmt.Sprint(c.Config.UseSearchIndex)).BoolVar(&c.useIndex)
+}
+
+// Run executes this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.ParseContext.
+func (c *SearchCommand) run(ctx *kingpin.ParseContext) error {
+	queryString := strings.Join(c.query, " ")
+	q, err := search.ParseQuery(queryString)
+	if err != nil {
+		return fmt.Errorf("Invalid query: %w", err)
+	}
+
+	if c.Config.FindCommand != "" && !c.builtin && q.IsPlain() {
+		return c.runExternal(q.Terms[0].Text)
+	}
+
+	var idx *search.InvertedIndex
+	if c.useIndex {
+		if c.Config.SearchIndexFile == "" {
+			return fmt.Errorf("No search index file configured.")
+		}
+		idx, err = search.LoadInvertedIndex(c.Config.SearchIndexFile)
+		if err != nil {
+			return err
+		}
+		err = idx.Update(c.Config.DocumentRoots)
+		if err != nil {
+			return err
+		}
+		err = idx.Save(c.Config.SearchIndexFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	matches, err := search.Search(c.Config.DocumentRoots, q, idx)
+	if err != nil {
+		return err
+	}
+
+	highlight := isTerminal(os.Stdout)
+	for _, m := range matches {
+		fmt.Printf("%s:%d:%s\n", m.File, m.Line, highlightMatch(m, highlight))
+	}
+
+	return nil
+}
+
+// runExternal searches for the given text via the configured FindCommand.
+//
+// The text and the document roots are appended to the FindCommand. The
+// output of the FindCommand is written to stdout. Exit code 1 of the
+// FindCommand means that nothing matched (like for grep and ripgrep).
+func (c *SearchCommand) runExternal(text string) error {
+	args := []string{cmdline.Quote(text)}
+	for _, root := range c.Config.DocumentRoots {
+		args = append(args, cmdline.Quote(root))
+	}
+
+	cmdLine := c.Config.FindCommand + " " + strings.Join(args, " ")
+	err := cmdline.Execute(cmdLine, "", nil, os.Stdout, os.Stderr)
+	var execErr *cmdline.ExecError
+	if errors.As(err, &execErr) && execErr.ExitCode == 1 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error searching: %w", err)
+	}
+
+	return nil
+}
+
+// highlightMatch returns the text of the given Match. If highlight is
+// true, the matched terms are highlighted via ANSI escape sequences.
+func highlightMatch(m search.Match, highlight bool) string {
+	if !highlight {
+		return m.Text
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, r := range m.Ranges {
+		// ranges of different terms may overlap
+		start, end := r[0], r[1]
+		if start < pos {
+			start = pos
+		}
+		if start >= end {
+			continue
+		}
+		b.WriteString(m.Text[pos:start])
+		b.WriteString("\x1b[1;31m")
+		b.WriteString(m.Text[start:end])
+		b.WriteString("\x1b[0m")
+		pos = end
+	}
+	b.WriteString(m.Text[pos:])
+
+	return b.String()
+}
+
+// isTerminal returns true if the given file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"testing"
+
+	"poiu.de/brief/config"
+)
+
+func TestSearchExternal(t *testing.T) {
+	cases := []struct {
+		findCommand string
+		fails       bool
+	}{
+		{"true", false},
+		// nothing matched
+		{"false", false},
+		{"sh -c 'exit 2'", true},
+	}
+
+	for _, c := range cases {
+		cmd := &SearchCommand{Config: config.Config{FindCommand: c.findCommand, DocumentRoots: []string{t.TempDir()}}}
+		err := cmd.runExternal("text")
+		if c.fails && err == nil {
+			t.Errorf("runExternal() with %q succeeded, expected error", c.findCommand)
+		} else if !c.fails && err != nil {
+			t.Errorf("runExternal() with %q failed: %v", c.findCommand, err)
+		}
+	}
+}
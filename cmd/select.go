@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"poiu.de/brief/address"
+	"poiu.de/brief/cmdline"
+	"poiu.de/brief/config"
+	"poiu.de/brief/index"
+	"poiu.de/brief/selector"
+)
+
+// selectLetter lets the user select one of the letters in the document
+// roots and returns the path of its .brf file.
+//
+// The preview of each letter shows its subject and recipient.
+func selectLetter(cfg *config.Config) (string, error) {
+	idx, err := loadIndex(cfg, true)
+	if err != nil {
+		return "", err
+	}
+
+	entries := idx.Find(index.Query{})
+	items := make([]selector.Item, len(entries))
+	for i, e := range entries {
+		items[i] = selector.Item{
+			Label: fmt.Sprintf("%s  %s  %s  (%s)", e.Date, e.To, e.Subject, filepath.Base(e.File)),
+			Value: e.File,
+			Preview: []string{
+				"Subject: " + e.Subject,
+				"To:      " + e.To,
+				"Date:    " + e.Date,
+				"File:    " + e.File,
+			},
+		}
+	}
+
+	item, err := selector.Select(items, "Letter", cfg.ListerCommand)
+	if err != nil {
+		return "", err
+	}
+	return item.Value, nil
+}
+
+// selectAddress lets the user select one of the addresses in the given
+// address file and returns its id.
+//
+// The preview of each address shows its postal address.
+func selectAddress(addressFile string, prompt string, cfg *config.Config) (string, error) {
+	addresses, err := address.ReadFromFile(addressFile)
+	if err != nil {
+		return "", err
+	}
+
+	ids := make([]string, 0, len(addresses))
+	for id := range addresses {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	items := make([]selector.Item, len(ids))
+	for i, id := range ids {
+		a := addresses[id]
+		// recipients have a name and postal address, senders a fromName
+		// and fromAddress
+		name := a.Value("name")
+		preview := a.PostalLines()
+		if name == "" {
+			name = a.Value("fromName")
+			preview = make([]string, 0)
+			preview = append(preview, a.Fields["fromName"]...)
+			preview = append(preview, a.Fields["fromAddress"]...)
+		}
+		items[i] = selector.Item{
+			Label:   strings.TrimSpace(id + "  " + name),
+			Value:   id,
+			Preview: preview,
+		}
+	}
+
+	item, err := selector.Select(items, prompt, cfg.ListerCommand)
+	if err != nil {
+		return "", err
+	}
+	return item.Value, nil
+}
+
+// selectTemplate lets the user select one of the templates in the
+// template directory and returns its name.
+func selectTemplate(cfg *config.Config) (string, error) {
+	files, err := ioutil.ReadDir(cfg.TexTemplateDir)
+	if err != nil {
+		return "", fmt.Errorf("Error reading template directory %s: %w", cfg.TexTemplateDir, err)
+	}
+
+	items := make([]selector.Item, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		items = append(items, selector.Item{Label: f.Name(), Value: f.Name()})
+	}
+
+	item, err := selector.Select(items, "Template", cfg.ListerCommand)
+	if err != nil {
+		return "", err
+	}
+	return item.Value, nil
+}
+
+// openEditor opens the given file in the configured editor and waits until
+// the editor is closed.
+func openEditor(cfg *config.Config, file string) error {
+	if cfg.Editor == "" {
+		return fmt.Errorf("No editor configured.")
+	}
+
+	cmdLine := cfg.Editor + " " + cmdline.Quote(file)
+	err := cmdline.Execute(cmdLine, "", os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("Error executing editor: %w", err)
+	}
+	return nil
+}
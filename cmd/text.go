@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+	"poiu.de/brief/backend"
+	"poiu.de/brief/config"
+)
+
+// TextCommand prints a .brf file as wrapped plain text via the text
+// backend.
+type TextCommand struct {
+	// The .brf file to render.
+	brfFile string
+	// The maximum width of the lines.
+	width int
+	// Whether to render the letter as email body. This leaves out the postal
+	// address of the recipient.
+	email bool
+	// The configuration for this BriefCmd.
+	Config config.Config
+}
+
+// Configure configures the command line parser for this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.Application.
+func (c *TextCommand) Configure(app *kingpin.Application) {
+	text := app.Command("text", "Print the given <brfFile> as plain text.").Action(c.run)
+	text.Arg("brfFile", "brf file to render.").Required().StringVar(&c.brfFile)
+	text.Flag("width", "The maximum width of the lines.").Short('w').Default(fmt.Sprint(backend.DefaultTextWidth)).IntVar(&c.width)
+	text.Flag("email", "Render as email body without the postal address of the recipient.").BoolVar(&c.email)
+}
+
+// Run executes this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.ParseContext.
+func (c *TextCommand) run(ctx *kingpin.ParseContext) error {
+	if c.width < 1 {
+		return fmt.Errorf("Invalid width %d", c.width)
+	}
+
+	l, err := loadLetter(c.brfFile, nil, &c.Config)
+	if err != nil {
+		return err
+	}
+
+	err = l.Validate()
+	if err != nil {
+		return err
+	}
+
+	b := backend.NewText(&c.Config)
+	b.Width = c.width
+	b.Email = c.email
+	_, err = io.WriteString(os.Stdout, b.RenderText(Context, l))
+	return err
+}
@@ -1,4 +1,57 @@
 package cmd
 
-func Validate(args []string) {
+import (
+	"fmt"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+	"poiu.de/brief/backend"
+	"poiu.de/brief/config"
+)
+
+// ValidateCommand checks whether a .brf file can be converted without
+// running any external application.
+//
+// It is stricter than converting and also fails for DATE sections that
+// cannot be parsed as a date.
+type ValidateCommand struct {
+	// The .brf file to validate.
+	brfFile string
+	// The configuration for this BriefCmd.
+	Config config.Config
+}
+
+// Configure configures the command line parser for this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.Application.
+func (c *ValidateCommand) Configure(app *kingpin.Application) {
+	validate := app.Command("validate", "Check the given <brfFile> for errors.").Action(c.run)
+	validate.Arg("brfFile", "brf file to validate.").Required().StringVar(&c.brfFile)
+}
+
+// Run executes this BriefCmd
+//
+// kingpin is used for commandline parsing and therefore the only
+// accepted parameter is a pointer to a kingpin.ParseContext.
+func (c *ValidateCommand) run(ctx *kingpin.ParseContext) error {
+	l, err := loadLetter(c.brfFile, nil, &c.Config)
+	if err != nil {
+		return err
+	}
+
+	b, err := backend.ForLetter(l, "", &c.Config)
+	if err != nil {
+		return err
+	}
+	err = backend.Validate(b, l)
+	if err != nil {
+		return err
+	}
+
+	// free-text dates can be rendered, but the date placeholders refer to
+	// the current date then
+	if l.DateText != "" {
+		return fmt.Errorf("Invalid letter %s:\n  DATE section %q is not a date. Placeholders like ${date.iso} use the current date instead.", l.BrfFile, l.DateText)
+	}
+	return nil
 }
@@ -1,8 +1,46 @@
 package cmd
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"poiu.de/brief/config"
 )
 
 func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	senderList := filepath.Join(dir, "senders")
+	if err := os.WriteFile(senderList, []byte("address: me\nname: Max Mustermann\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		date    string
+		problem string
+	}{
+		{"", ""},
+		{"2020-03-03", ""},
+		{"+3d", ""},
+		{"im Mai 2024", `DATE section "im Mai 2024" is not a date`},
+	}
+
+	for _, c := range cases {
+		brfFile := filepath.Join(dir, "a.brf")
+		if err := os.WriteFile(brfFile, []byte(".FROM\nme\n.DATE\n"+c.date+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := &ValidateCommand{brfFile: brfFile, Config: config.Config{SenderList: senderList, Language: "de"}}
+		err := cmd.run(nil)
+		if c.problem == "" {
+			if err != nil {
+				t.Errorf("Validating date %q failed: %v", c.date, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.problem) {
+			t.Errorf("Validating date %q == %v, expected error containing %q", c.date, err, c.problem)
+		}
+	}
 }
@@ -0,0 +1,182 @@
+package cmdline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The number of lines of stdout and stderr an ExecError keeps.
+const tailLines = 20
+
+// ExecError is returned if an external command fails.
+//
+// It carries the last lines of stdout and stderr of the failed command,
+// unless those were written to a file (like os.Stdout), in which case the
+// user has already seen them.
+type ExecError struct {
+	// The executed command line (after substituting all placeholders).
+	CmdLine string
+	// The exit code of the failed command or -1 if it didn't exit (for
+	// example because it couldn't be started or was killed).
+	ExitCode int
+	// The time the commands ran.
+	Duration time.Duration
+	// The last lines of stdout.
+	Stdout string
+	// The last lines of stderr.
+	Stderr string
+	// The underlying error.
+	Err error
+}
+
+// Error returns a short description of this ExecError including the last
+// line written to stderr.
+func (e *ExecError) Error() string {
+	var msg string
+	if e.ExitCode >= 0 {
+		msg = fmt.Sprintf("Command %s failed with exit code %d after %v", e.CmdLine, e.ExitCode, e.Duration.Round(time.Millisecond))
+	} else {
+		msg = fmt.Sprintf("Command %s failed after %v: %v", e.CmdLine, e.Duration.Round(time.Millisecond), e.Err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(e.Stderr), "\n"); lines[len(lines)-1] != "" {
+		msg += ": " + strings.TrimSpace(lines[len(lines)-1])
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// Details returns the last lines of stdout and stderr of the failed
+// command, ready to be printed to the user.
+//
+// An empty string is returned if there is no output.
+func (e *ExecError) Details() string {
+	b := &strings.Builder{}
+	for _, output := range []struct {
+		name string
+		text string
+	}{{"stdout", e.Stdout}, {"stderr", e.Stderr}} {
+		if strings.TrimSpace(output.text) == "" {
+			continue
+		}
+		fmt.Fprintf(b, "--- last lines of %s ---\n", output.name)
+		b.WriteString(output.text)
+		if !strings.HasSuffix(output.text, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// newExecError creates an ExecError for the given failed commands.
+func newExecError(cmds Pipeline, start time.Time, stdout *tailWriter, stderr *tailWriter, err error) *ExecError {
+	e := &ExecError{
+		CmdLine:  pipelineString(cmds),
+		ExitCode: -1,
+		Duration: time.Since(start),
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Err:      err,
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		e.ExitCode = exitErr.ExitCode()
+	}
+	return e
+}
+
+// pipelineString returns the given Pipeline as a command line.
+func pipelineString(cmds Pipeline) string {
+	s := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		words := []string{quoteIfNecessary(cmd.CmdName)}
+		for _, arg := range cmd.Args {
+			words = append(words, quoteIfNecessary(arg))
+		}
+		if cmd.StdinFile != "" {
+			words = append(words, "<", quoteIfNecessary(cmd.StdinFile))
+		}
+		if cmd.StdoutFile != "" && cmd.AppendStdout {
+			words = append(words, ">>", quoteIfNecessary(cmd.StdoutFile))
+		} else if cmd.StdoutFile != "" {
+			words = append(words, ">", quoteIfNecessary(cmd.StdoutFile))
+		}
+		s[i] = strings.Join(words, " ")
+	}
+	return strings.Join(s, " | ")
+}
+
+// quoteIfNecessary quotes the given word via Quote if it contains
+// characters with a special meaning for the Parser.
+func quoteIfNecessary(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\r\n'\"\\$~%|&<>") {
+		return Quote(s)
+	}
+	return s
+}
+
+// tailWriter is an io.Writer that keeps the last lines written to it.
+//
+// It is safe for concurrent use.
+type tailWriter struct {
+	mu sync.Mutex
+	// The number of lines to keep.
+	lines int
+	buf   []byte
+}
+
+// newTailWriter creates a new tailWriter that keeps the given number of
+// lines.
+func newTailWriter(lines int) *tailWriter {
+	return &tailWriter{lines: lines}
+}
+
+// Write appends the given bytes, dropping lines that are no longer needed.
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	// don't trim on every write
+	if bytes.Count(w.buf, []byte{'\n'}) > 2*w.lines {
+		w.buf = []byte(lastLines(string(w.buf), w.lines))
+	}
+	return len(p), nil
+}
+
+// String returns the kept lines.
+func (w *tailWriter) String() string {
+	if w == nil {
+		return ""
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return lastLines(string(w.buf), w.lines)
+}
+
+// lastLines returns the last n lines of the given string.
+func lastLines(s string, n int) string {
+	end := len(s)
+	if strings.HasSuffix(s, "\n") {
+		end--
+	}
+	for i := end - 1; i >= 0; i-- {
+		if s[i] == '\n' {
+			n--
+			if n == 0 {
+				return s[i+1:]
+			}
+		}
+	}
+	return s
+}
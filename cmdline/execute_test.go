@@ -0,0 +1,221 @@
+package cmdline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExecuteContext(t *testing.T) {
+	cases := []struct {
+		cmdLine string
+		stdin   string
+		stdout  string
+	}{
+		{"echo hello", "", "hello\n"},
+		{"cat", "from stdin", "from stdin"},
+		{"echo one two | wc -w", "", "2"},
+	}
+	for _, tt := range cases {
+		stdout := &strings.Builder{}
+		err := ExecuteContext(context.Background(), tt.cmdLine, "", strings.NewReader(tt.stdin), stdout, nil)
+		if err != nil {
+			t.Errorf("ExecuteContext(%q) returned error %v", tt.cmdLine, err)
+			continue
+		}
+		if strings.TrimSpace(stdout.String()) != strings.TrimSpace(tt.stdout) {
+			t.Errorf("ExecuteContext(%q) printed %q, expected %q", tt.cmdLine, stdout.String(), tt.stdout)
+		}
+	}
+}
+
+func TestExecuteContextTimeout(t *testing.T) {
+	cases := []string{
+		"sleep 10",
+		"sleep 10 | cat",
+		"cat | sleep 10",
+		// a child process holding stdout open must not block either
+		"sh -c 'sleep 10; echo done'",
+	}
+	for _, cmdLine := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		start := time.Now()
+		err := ExecuteContext(ctx, cmdLine, "", nil, &strings.Builder{}, nil)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("ExecuteContext(%q) returned error %v, expected %v", cmdLine, err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("ExecuteContext(%q) took %v after timeout", cmdLine, elapsed)
+		}
+	}
+}
+
+func TestExecuteContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stdout := &strings.Builder{}
+	err := ExecuteContext(ctx, "echo hello", "", nil, stdout, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ExecuteContext returned error %v, expected %v", err, context.Canceled)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("ExecuteContext executed command of cancelled context, printed %q", stdout.String())
+	}
+}
+
+func TestExecuteWorkingDir(t *testing.T) {
+	before, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = os.WriteFile(filepath.Join(dir, "file.txt"), []byte("content"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := &strings.Builder{}
+	err = Execute("cat file.txt", dir, nil, stdout, nil)
+	if err != nil {
+		t.Fatalf("Execute returned error %v", err)
+	}
+	if stdout.String() != "content" {
+		t.Errorf("Execute printed %q, expected %q", stdout.String(), "content")
+	}
+
+	after, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != before {
+		t.Errorf("Execute changed working directory from %s to %s", before, after)
+	}
+}
+
+func TestExecuteConcurrent(t *testing.T) {
+	const n = 20
+
+	dirs := make([]string, n)
+	for i := range dirs {
+		dirs[i] = t.TempDir()
+		content := strings.Repeat(fmt.Sprintf("line of %d\n", i), i+1)
+		err := os.WriteFile(filepath.Join(dirs[i], "file.txt"), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	outputs := make([]string, n)
+	for i := range dirs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stdout := &strings.Builder{}
+			stderr := &strings.Builder{}
+			errs[i] = Execute("cat file.txt missing.txt | grep line | wc -l", dirs[i], nil, stdout, stderr)
+			outputs[i] = strings.TrimSpace(stdout.String())
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range dirs {
+		// cat fails on the missing file, but only after printing file.txt
+		if errs[i] == nil {
+			t.Errorf("Execute in %s returned no error for missing file", dirs[i])
+		}
+		if expected := fmt.Sprint(i + 1); outputs[i] != expected {
+			t.Errorf("Execute in %s printed %q, expected %q", dirs[i], outputs[i], expected)
+		}
+	}
+}
+
+func TestExecuteSequenceAndRedirection(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("b\na\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Execute("sort < in.txt > out.txt && echo c >> out.txt", dir, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Execute returned error %v", err)
+	}
+	out, err := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "a\nb\nc\n" {
+		t.Errorf("Execute wrote %q, expected %q", out, "a\nb\nc\n")
+	}
+
+	// the second pipeline must not be executed if the first one fails
+	err = Execute("false && touch touched.txt", dir, nil, nil, nil)
+	if err == nil {
+		t.Errorf("Execute returned no error for failing command")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "touched.txt")); err == nil {
+		t.Errorf("Execute executed pipeline after failing pipeline")
+	}
+}
+
+func TestExecuteSyntaxError(t *testing.T) {
+	err := Execute("| cat", "", nil, nil, nil)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("Execute returned error %v, expected SyntaxError", err)
+	}
+}
+
+func TestExecuteExecError(t *testing.T) {
+	stdout := &strings.Builder{}
+	err := Execute(`sh -c 'for i in $(seq 1 50); do echo "line $i"; done; echo failure >&2; exit 3'`, "", nil, stdout, nil)
+
+	var execErr *ExecError
+	if !errors.As(err, &execErr) {
+		t.Fatalf("Execute returned error %v, expected ExecError", err)
+	}
+	if execErr.ExitCode != 3 {
+		t.Errorf("ExecError.ExitCode == %d, expected 3", execErr.ExitCode)
+	}
+	if !strings.HasPrefix(execErr.CmdLine, "sh -c ") {
+		t.Errorf("ExecError.CmdLine == %q, expected to start with %q", execErr.CmdLine, "sh -c ")
+	}
+	if execErr.Stderr != "failure\n" {
+		t.Errorf("ExecError.Stderr == %q, expected %q", execErr.Stderr, "failure\n")
+	}
+	if lines := strings.Split(strings.TrimSuffix(execErr.Stdout, "\n"), "\n"); len(lines) != tailLines || lines[0] != "line 31" || lines[tailLines-1] != "line 50" {
+		t.Errorf("ExecError.Stdout == %q, expected lines 31 to 50", execErr.Stdout)
+	}
+	if !strings.HasSuffix(execErr.Error(), ": failure") {
+		t.Errorf("ExecError.Error() == %q, expected to end with last line of stderr", execErr.Error())
+	}
+	// the output is still written to the given writer
+	if !strings.Contains(stdout.String(), "line 1\n") {
+		t.Errorf("Execute didn't write full output to stdout")
+	}
+}
+
+func TestExecuteVerbose(t *testing.T) {
+	verbose := &strings.Builder{}
+	VerboseOutput = verbose
+	defer func() { VerboseOutput = nil }()
+
+	err := Execute("echo hello", "", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Execute returned error %v", err)
+	}
+	if verbose.String() != "+ echo hello\nhello\n" {
+		t.Errorf("Execute wrote %q to VerboseOutput, expected %q", verbose.String(), "+ echo hello\nhello\n")
+	}
+}
@@ -0,0 +1,298 @@
+package cmdline
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenType is the type of a token of a command line.
+type tokenType int
+
+const (
+	// A word, like a command name, an argument or a file name
+	tokenWord tokenType = iota
+	// The pipe operator |
+	tokenPipe
+	// The sequence operator &&
+	tokenAnd
+	// The input redirection <
+	tokenRedirectIn
+	// The output redirection >
+	tokenRedirectOut
+	// The appending output redirection >>
+	tokenRedirectAppend
+)
+
+// token is a single token of a command line.
+type token struct {
+	typ tokenType
+	// The unquoted and expanded value of a word or the operator itself.
+	value string
+	// The byte offset of the token in the command line.
+	pos int
+}
+
+// SyntaxError is returned by the Parser for invalid command lines.
+type SyntaxError struct {
+	// The invalid command line.
+	CmdLine string
+	// The byte offset of the error in the command line.
+	Pos int
+	// The description of the error.
+	Msg string
+}
+
+// Error returns the description of this SyntaxError including the column
+// (in characters, starting at 1) at which it occurred.
+func (e *SyntaxError) Error() string {
+	column := utf8.RuneCountInString(e.CmdLine[:e.Pos]) + 1
+	return fmt.Sprintf("Syntax error in command line %s at column %d: %s", e.CmdLine, column, e.Msg)
+}
+
+// lexer splits a command line into tokens.
+//
+// It removes quotes and escapes from words and expands variables,
+// placeholders and the home directory.
+type lexer struct {
+	// The command line to tokenize.
+	input string
+	// The current byte offset in the input.
+	pos int
+	// The function to look up the value of variables.
+	getenv func(string) string
+	// The function to determine the home directory.
+	homeDir func() (string, error)
+	// The placeholders to substitute. May be nil.
+	placeholders Placeholders
+}
+
+// tokens returns all tokens of the command line.
+func (l *lexer) tokens() ([]token, error) {
+	tokens := make([]token, 0)
+	for {
+		l.skipBlanks()
+		if l.pos >= len(l.input) {
+			return tokens, nil
+		}
+
+		var t token
+		var err error
+		if isOperatorChar(l.input[l.pos]) {
+			t, err = l.operator()
+		} else {
+			t, err = l.word()
+		}
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+}
+
+// skipBlanks skips all whitespace at the current position.
+func (l *lexer) skipBlanks() {
+	for l.pos < len(l.input) && isBlank(l.input[l.pos]) {
+		l.pos++
+	}
+}
+
+// operator reads the operator at the current position.
+func (l *lexer) operator() (token, error) {
+	start := l.pos
+	rest := l.input[l.pos:]
+	switch {
+	case strings.HasPrefix(rest, "&&"):
+		l.pos += 2
+		return token{tokenAnd, "&&", start}, nil
+	case strings.HasPrefix(rest, ">>"):
+		l.pos += 2
+		return token{tokenRedirectAppend, ">>", start}, nil
+	case rest[0] == '|':
+		l.pos++
+		return token{tokenPipe, "|", start}, nil
+	case rest[0] == '<':
+		l.pos++
+		return token{tokenRedirectIn, "<", start}, nil
+	case rest[0] == '>':
+		l.pos++
+		return token{tokenRedirectOut, ">", start}, nil
+	default:
+		return token{}, l.syntaxError(start, "Running commands in the background via & is not supported")
+	}
+}
+
+// word reads the word at the current position.
+//
+// Quotes and escapes are removed and variables, placeholders and a leading
+// ~ are expanded.
+func (l *lexer) word() (token, error) {
+	start := l.pos
+	b := &strings.Builder{}
+
+	l.expandHomeDir(b)
+
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		var err error
+		switch {
+		case isBlank(c) || isOperatorChar(c):
+			return token{tokenWord, b.String(), start}, nil
+		case c == '\\':
+			if l.pos+1 >= len(l.input) {
+				return token{}, l.syntaxError(l.pos, "Missing character to escape after \\")
+			}
+			_, size := utf8.DecodeRuneInString(l.input[l.pos+1:])
+			b.WriteString(l.input[l.pos+1 : l.pos+1+size])
+			l.pos += 1 + size
+		case c == '\'':
+			end := strings.IndexByte(l.input[l.pos+1:], '\'')
+			if end < 0 {
+				return token{}, l.syntaxError(l.pos, "Unterminated single quote")
+			}
+			b.WriteString(l.input[l.pos+1 : l.pos+1+end])
+			l.pos += end + 2
+		case c == '"':
+			err = l.doubleQuoted(b)
+		case c == '$':
+			err = l.variable(b)
+		case c == '%':
+			l.placeholder(b)
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+		if err != nil {
+			return token{}, err
+		}
+	}
+
+	return token{tokenWord, b.String(), start}, nil
+}
+
+// expandHomeDir expands a ~ at the current position (the start of a word)
+// to the home directory of the user, if it is followed by a slash or ends
+// the word.
+//
+// If the home directory cannot be determined, the ~ is left as is.
+func (l *lexer) expandHomeDir(b *strings.Builder) {
+	if l.pos >= len(l.input) || l.input[l.pos] != '~' {
+		return
+	}
+	if next := l.pos + 1; next < len(l.input) && l.input[next] != '/' && !isBlank(l.input[next]) && !isOperatorChar(l.input[next]) {
+		return
+	}
+
+	home, err := l.homeDir()
+	if err != nil || home == "" {
+		return
+	}
+	b.WriteString(home)
+	l.pos++
+}
+
+// doubleQuoted reads the double quoted string at the current position.
+//
+// Inside double quotes variables and placeholders are expanded and the
+// backslash only escapes the characters \, ", $ and `.
+func (l *lexer) doubleQuoted(b *strings.Builder) error {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			return nil
+		case c == '\\' && l.pos+1 < len(l.input) && strings.IndexByte("\\\"$`", l.input[l.pos+1]) >= 0:
+			b.WriteByte(l.input[l.pos+1])
+			l.pos += 2
+		case c == '$':
+			if err := l.variable(b); err != nil {
+				return err
+			}
+		case c == '%':
+			l.placeholder(b)
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return l.syntaxError(start, "Unterminated double quote")
+}
+
+// variable expands the variable reference ($NAME or ${NAME}) at the current
+// position. Undefined variables expand to an empty string.
+//
+// A $ that is not followed by a variable name is taken literally.
+func (l *lexer) variable(b *strings.Builder) error {
+	start := l.pos
+	l.pos++
+
+	if l.pos < len(l.input) && l.input[l.pos] == '{' {
+		end := strings.IndexByte(l.input[l.pos:], '}')
+		if end < 0 {
+			return l.syntaxError(start, "Unterminated variable reference ${")
+		}
+		name := l.input[l.pos+1 : l.pos+end]
+		if !isVariableName(name) {
+			return l.syntaxError(start, fmt.Sprintf("Invalid variable name %q", name))
+		}
+		b.WriteString(l.getenv(name))
+		l.pos += end + 1
+		return nil
+	}
+
+	end := l.pos
+	for end < len(l.input) && isVariableChar(l.input[end], end == l.pos) {
+		end++
+	}
+	if end == l.pos {
+		b.WriteByte('$')
+		return nil
+	}
+	b.WriteString(l.getenv(l.input[l.pos:end]))
+	l.pos = end
+	return nil
+}
+
+// placeholder substitutes the placeholder at the current position.
+func (l *lexer) placeholder(b *strings.Builder) {
+	value, size := l.placeholders.expand(l.input, l.pos)
+	b.WriteString(value)
+	l.pos += size
+}
+
+// syntaxError returns a SyntaxError for the given position.
+func (l *lexer) syntaxError(pos int, msg string) error {
+	return &SyntaxError{CmdLine: l.input, Pos: pos, Msg: msg}
+}
+
+// isBlank returns true if the given character separates words.
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// isOperatorChar returns true if the given character starts an operator.
+func isOperatorChar(c byte) bool {
+	return c == '|' || c == '&' || c == '<' || c == '>'
+}
+
+// isVariableChar returns true if the given character is allowed in a
+// variable name. Digits are not allowed as first character.
+func isVariableChar(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+}
+
+// isVariableName returns true if the given string is a valid variable name.
+func isVariableName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isVariableChar(s[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
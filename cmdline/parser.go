@@ -5,14 +5,45 @@
 package cmdline
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
+// Parser parses command lines with a shell-like syntax.
+//
+// The syntax is a small subset of the syntax of POSIX shells:
+//
+//   - Words are separated by whitespace.
+//   - Single quotes (') enclose text that is taken literally.
+//   - Double quotes (") enclose text in which variables are expanded and the
+//     backslash escapes the characters \, ", $ and `.
+//   - Outside of quotes the backslash escapes any character.
+//   - Quotes may appear in the middle of a word, like --opt="a b".
+//   - Variables ($NAME or ${NAME}) are expanded outside of single quotes.
+//     The expanded value is never split into multiple words.
+//   - Placeholders (like %f) are substituted outside of single quotes, like
+//     variables. Use %% for a literal percent sign. See Placeholders.
+//   - A ~ at the start of a word is expanded to the home directory, if it
+//     is followed by a slash or ends the word.
+//   - Commands are connected via pipes (|) and pipelines are executed one
+//     after the other as long as they succeed (&&).
+//   - Stdin of a pipeline can be read from a file (<) and stdout written to
+//     a file (>) or appended to a file (>>).
 type Parser struct {
+	// The function to look up the value of variables.
+	Getenv func(string) string
+	// The function to determine the home directory.
+	HomeDir func() (string, error)
+	// The placeholders to substitute. If nil, percent signs are taken
+	// literally.
+	Placeholders Placeholders
 }
 
 // Cmd specifies an external command that can be called via
@@ -20,146 +51,264 @@ type Parser struct {
 type Cmd struct {
 	CmdName string
 	Args    []string
+	// The file to read stdin from. Empty if stdin is not redirected.
+	StdinFile string
+	// The file to write stdout to. Empty if stdout is not redirected.
+	StdoutFile string
+	// Whether to append to the StdoutFile instead of overwriting it.
+	AppendStdout bool
 }
 
-// newCmd creates a new Cmd from the given slice of tokens.
-//
-// The first token is used as the command name, all other tokens as
-// arguments to that command.
-func newCmd(tokens []string) *Cmd {
-	cmd := new(Cmd)
-	if len(tokens) > 0 {
-		cmd.CmdName = tokens[0]
-	}
-	if len(tokens) > 1 {
-		cmd.Args = tokens[1:]
-	}
-	return cmd
-}
+// Pipeline is a sequence of Cmds, where the stdout of each Cmd is piped to
+// the stdin of the next Cmd.
+type Pipeline []Cmd
 
 type parser interface {
-	Parse(s string) []string
+	Parse(s string) ([]Pipeline, error)
 }
 
 // NewParser creates a new parser for parsing executable commands +
 // arguments.
+//
+// Variables are looked up in the environment of the current process.
 func NewParser() *Parser {
-	return new(Parser)
+	return &Parser{Getenv: os.Getenv, HomeDir: os.UserHomeDir}
 }
 
-// Parse tokenizes the given string with a command line into
-// the command and its arguments.
-//
-// It splits the tokens by whitespace. To include whitespace inside a
-// token enclose the token in either single (') or double (") quotes.
-//
-// The resulting slice should be usable to feed it into os/exec.Command
-// like:
-//   p := cmdline.NewParser()
-//   cmdLineTokens := p.Parse("mycmd arg1 'arg two'")
-//   exec.Command(cmdLineTokens...)
-func (p *Parser) Parse(s string) []Cmd {
-	cmds := make([]Cmd, 0)
-	tokens := make([]string, 0)
-	word := make([]rune, 0)
-
-	var quoteChar rune
-	for _, c := range s {
-		switch c {
-		case '\'', '"':
-			if quoteChar == c {
-				quoteChar = 0
+// Parse parses the given string with a command line into pipelines of
+// commands and their arguments.
+//
+// The returned pipelines are meant to be executed one after the other, as
+// long as they succeed (they are separated by && in the command line).
+// An empty command line results in an empty slice.
+//
+// If the command line is invalid, a *SyntaxError is returned.
+//
+// The Cmds in the resulting pipelines should be usable to feed them into
+// os/exec.Command like:
+//
+//	p := cmdline.NewParser()
+//	pipelines, err := p.Parse("mycmd arg1 'arg two'")
+//	cmd := pipelines[0][0]
+//	exec.Command(cmd.CmdName, cmd.Args...)
+func (p *Parser) Parse(s string) ([]Pipeline, error) {
+	l := &lexer{input: s, getenv: p.Getenv, homeDir: p.HomeDir, placeholders: p.Placeholders}
+	tokens, err := l.tokens()
+	if err != nil {
+		return nil, err
+	}
+
+	pipelines := make([]Pipeline, 0)
+	pipeline := make(Pipeline, 0)
+	// the command currently being parsed
+	var cmd *Cmd
+	// the position of the output redirection of the current command
+	stdoutPos := -1
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.typ {
+		case tokenWord:
+			if cmd == nil {
+				cmd = &Cmd{CmdName: t.value}
 			} else {
-				quoteChar = c
+				cmd.Args = append(cmd.Args, t.value)
 			}
-		case ' ', '\t':
-			if quoteChar != 0 {
-				word = append(word, c)
-			} else if len(word) > 0 {
-				tokens = append(tokens, string(word))
-				word = make([]rune, 0)
-			} else {
-				// ignore multiple consecutive whitespace characters
+
+		case tokenRedirectIn, tokenRedirectOut, tokenRedirectAppend:
+			if cmd == nil {
+				return nil, l.syntaxError(t.pos, fmt.Sprintf("Missing command before %s", t.value))
+			}
+			if i+1 >= len(tokens) || tokens[i+1].typ != tokenWord {
+				return nil, l.syntaxError(t.pos, fmt.Sprintf("Missing file name after %s", t.value))
 			}
-		case '|':
-			if quoteChar != 0 {
-				word = append(word, c)
+			i++
+			fileName := tokens[i].value
+			if t.typ == tokenRedirectIn {
+				if len(pipeline) > 0 {
+					return nil, l.syntaxError(t.pos, "Only the first command of a pipeline can read from a file")
+				}
+				if cmd.StdinFile != "" {
+					return nil, l.syntaxError(t.pos, "Multiple input redirections")
+				}
+				cmd.StdinFile = fileName
 			} else {
-				//TODO: Start new Cmd
-				cmd := newCmd(tokens)
-				cmds = append(cmds, *cmd)
-				tokens = make([]string, 0)
+				if cmd.StdoutFile != "" {
+					return nil, l.syntaxError(t.pos, "Multiple output redirections")
+				}
+				cmd.StdoutFile = fileName
+				cmd.AppendStdout = t.typ == tokenRedirectAppend
+				stdoutPos = t.pos
 			}
-		default:
-			word = append(word, c)
-		}
-	}
 
-	if len(word) > 0 {
-		tokens = append(tokens, string(word))
+		case tokenPipe, tokenAnd:
+			if cmd == nil {
+				return nil, l.syntaxError(t.pos, fmt.Sprintf("Missing command before %s", t.value))
+			}
+			if t.typ == tokenPipe && cmd.StdoutFile != "" {
+				return nil, l.syntaxError(stdoutPos, "Only the last command of a pipeline can write to a file")
+			}
+			pipeline = append(pipeline, *cmd)
+			cmd = nil
+			stdoutPos = -1
+			if t.typ == tokenAnd {
+				pipelines = append(pipelines, pipeline)
+				pipeline = make(Pipeline, 0)
+			}
+		}
 	}
 
-	if len(tokens) > 0 {
-		cmd := newCmd(tokens)
-		cmds = append(cmds, *cmd)
+	if cmd == nil {
+		if len(tokens) > 0 {
+			last := tokens[len(tokens)-1]
+			return nil, l.syntaxError(last.pos, fmt.Sprintf("Missing command after %s", last.value))
+		}
+		return pipelines, nil
 	}
+	pipeline = append(pipeline, *cmd)
+	pipelines = append(pipelines, pipeline)
 
-	return cmds
+	return pipelines, nil
 }
 
+// VerboseOutput receives the command lines and the output of all executed
+// commands if it is not nil. This is meant for debugging failing commands.
+var VerboseOutput io.Writer
+
 // Execute executes the given cmdLine.
 //
+// It is the same as ExecuteContext with a context that is never cancelled.
+func Execute(cmdLine string, workingDir string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	return ExecuteContext(context.Background(), cmdLine, workingDir, stdin, stdout, stderr)
+}
+
+// ExecuteContext executes the given cmdLine.
+//
 // The cmdLine is a string containing a command and arguments to that
-// command, separated by whitespace.
+// command, separated by whitespace. See Parser for the supported syntax.
 // If an argument contains whitespace it needs to be enclosed in single or
 // double quotes, for example: `ls -lha "my file with whitespace"`.
 //
-// Execute supports pipes between commands. Use the pipe symbol to indicate
-// that the stdout of one commands needs to be piped to stdin of another
-// command, for example: `cat myFile | wc -l`
+// ExecuteContext supports pipes between commands. Use the pipe symbol to
+// indicate that the stdout of one commands needs to be piped to stdin of
+// another command, for example: `cat myFile | wc -l`
+// Multiple pipelines can be separated by &&. They are executed one after
+// the other until one of them fails, for example: `make && make install`.
 //
-// If necessary a working dir may be given. This directory will be switched
-// to before executing the first command. After the last command finished
-// the working directory will be reverted to the one before this function
-// was called.
-// If it is an empty string, the working directory will not be changed.
+// If necessary a working dir may be given. All commands will be executed in
+// this directory and relative file names of redirections are resolved
+// against it. If it is an empty string, the current working directory is
+// used.
+// The working directory of the calling process is never changed. Therefore
+// ExecuteContext may safely be called from concurrent goroutines.
 //
 // If necessary, stdin, stdout and stderr may be given to attach to the
 // command. They may be nil.
 // When multiple piped commands are given in the cmdLine, stdin will be
 // attached to the first, stdout to the last and stderr to all commands.
-func Execute(cmdLine string, workingDir string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
-	// change working directory if necessary
-	if workingDir != "" {
-		curWorkingDir, err := os.Getwd()
+// Redirections in the cmdLine take precedence over the given stdin and
+// stdout.
+//
+// If the given context is cancelled (or its deadline is exceeded) before
+// all commands finished, all commands are killed and the error of the
+// context is returned (wrapped).
+// If the context can be cancelled and stdin is not a terminal, each command
+// is started in its own process group, so that any child processes are
+// killed as well. Commands reading from a terminal stay in the foreground
+// process group to be able to interact with the user. They receive an
+// interrupt (Ctrl-C) from the terminal themselves.
+func ExecuteContext(ctx context.Context, cmdLine string, workingDir string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	return ExecuteWithPlaceholders(ctx, cmdLine, nil, workingDir, stdin, stdout, stderr)
+}
+
+// ExecuteWithPlaceholders executes the given cmdLine after substituting the
+// given placeholders.
+//
+// The placeholders are substituted into the words of the parsed cmdLine,
+// therefore their values need no quoting. See ExecuteContext for a
+// description of the other parameters.
+func ExecuteWithPlaceholders(ctx context.Context, cmdLine string, placeholders Placeholders, workingDir string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("Not executing commands %s: %w", cmdLine, err)
+	}
+
+	p := NewParser()
+	p.Placeholders = placeholders
+	pipelines, err := p.Parse(cmdLine)
+	if err != nil {
+		return err
+	}
+	if len(pipelines) == 0 {
+		return fmt.Errorf("No command given")
+	}
+
+	for _, pipeline := range pipelines {
+		err := executePipeline(ctx, pipeline, workingDir, stdin, stdout, stderr)
 		if err != nil {
-			return fmt.Errorf("Cannot determince current working directory. Not switching to %s: %w", workingDir, err)
+			return err
 		}
+	}
 
-		defer func() {
-			err := os.Chdir(curWorkingDir)
-			if err != nil {
-				//FIXME: Only log a warning message? Or return a specific error type as Warning?
-				log.Println(fmt.Errorf("Cannot change working directory back from %s to %s: %w", workingDir, curWorkingDir, err))
-			}
-		}()
+	return nil
+}
 
-		err = os.Chdir(workingDir)
+// executePipeline executes the commands of the given Pipeline.
+//
+// If the commands fail, an *ExecError is returned.
+// See ExecuteContext for a description of the parameters.
+func executePipeline(ctx context.Context, cmds Pipeline, workingDir string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// apply the redirections
+	if stdinFile := cmds[0].StdinFile; stdinFile != "" {
+		f, err := os.Open(resolvePath(workingDir, stdinFile))
 		if err != nil {
-			return fmt.Errorf("Cannot change working directory to %s: %w", workingDir, err)
+			return fmt.Errorf("Cannot open %s for reading: %w", stdinFile, err)
 		}
+		defer f.Close()
+		stdin = f
+	}
+	if last := cmds[len(cmds)-1]; last.StdoutFile != "" {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if last.AppendStdout {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		f, err := os.OpenFile(resolvePath(workingDir, last.StdoutFile), flags, 0644)
+		if err != nil {
+			return fmt.Errorf("Cannot open %s for writing: %w", last.StdoutFile, err)
+		}
+		defer f.Close()
+		stdout = f
 	}
-
-	p := NewParser()
-	cmds := p.Parse(cmdLine)
 
 	commands := make([]*exec.Cmd, len(cmds))
 
+	start := time.Now()
+	stdout, stdoutTail := capture(stdout)
+	stderr, stderrTail := capture(stderr)
+	if VerboseOutput != nil {
+		fmt.Fprintf(VerboseOutput, "+ %s\n", pipelineString(cmds))
+	}
+
+	// the commands of a pipeline write to stderr concurrently (files are
+	// passed to the commands directly and therefore need no guard)
+	if _, isFile := stderr.(*os.File); stderr != nil && !isFile && len(cmds) > 1 {
+		stderr = &syncWriter{w: stderr}
+	}
+
 	// prepare the actual Command objects to execute
+	cancellable := ctx.Done() != nil
+	ownProcessGroup := cancellable && !isTerminal(stdin)
 	for i, cmd := range cmds {
 		commands[i] = exec.Command(cmd.CmdName, cmd.Args...)
+		commands[i].Dir = workingDir
 		// write stderr to each command
 		commands[i].Stderr = stderr
+		if ownProcessGroup {
+			setProcessGroup(commands[i])
+		}
 	}
 	// wire stdin to the first and stdout to the last Command
 	commands[0].Stdin = stdin
@@ -175,18 +324,122 @@ func Execute(cmdLine string, workingDir string, stdin io.Reader, stdout io.Write
 	}
 
 	// now execute all the commands
+	started := make([]*exec.Cmd, 0, len(commands))
+	var err error
 	for _, command := range commands {
-		err := command.Start()
+		err = command.Start()
 		if err != nil {
-			return fmt.Errorf("Error executing commands %s: %w", cmdLine, err)
+			err = newExecError(cmds, start, stdoutTail, stderrTail, err)
+			break
+		}
+		started = append(started, command)
+	}
+	if err != nil {
+		// don't leave the already started commands behind
+		for _, command := range started {
+			killProcess(command)
+			command.Wait()
 		}
+		return err
 	}
+
+	// kill all commands when the context is cancelled
+	if cancellable {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				for _, command := range started {
+					killProcess(command)
+				}
+			case <-done:
+			}
+		}()
+	}
+
 	for i := len(commands) - 1; i >= 0; i-- {
-		err := commands[i].Wait()
-		if err != nil {
-			return fmt.Errorf("Error executing commands %s: %w", cmdLine, err)
+		waitErr := commands[i].Wait()
+		if waitErr != nil && err == nil {
+			err = waitErr
 		}
 	}
 
+	// report the cancellation instead of the failure of the killed commands
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		err = ctxErr
+	}
+	if err != nil {
+		return newExecError(cmds, start, stdoutTail, stderrTail, err)
+	}
 	return nil
 }
+
+// capture returns an io.Writer that writes to the given io.Writer and keeps
+// the last lines written to it (for an ExecError). If VerboseOutput is set,
+// they are written to it, too.
+//
+// Files (like os.Stdout) are returned unchanged, as the user sees their
+// output anyway and commands may rely on them being terminals.
+func capture(w io.Writer) (io.Writer, *tailWriter) {
+	if _, isFile := w.(*os.File); isFile {
+		return w, nil
+	}
+
+	tail := newTailWriter(tailLines)
+	writers := []io.Writer{tail}
+	if w != nil {
+		writers = append(writers, w)
+	}
+	if VerboseOutput != nil {
+		writers = append(writers, VerboseOutput)
+	}
+	return io.MultiWriter(writers...), tail
+}
+
+// resolvePath resolves the given file name against the given working
+// directory, unless it is absolute or the working directory is empty.
+func resolvePath(workingDir string, fileName string) string {
+	if workingDir == "" || filepath.IsAbs(fileName) {
+		return fileName
+	}
+	return filepath.Join(workingDir, fileName)
+}
+
+// syncWriter is an io.Writer that serializes concurrent writes to the
+// underlying io.Writer.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write writes the given bytes to the underlying io.Writer.
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// isTerminal returns true if the given reader is a terminal.
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok || f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Quote encloses the given string in single quotes, so that it is treated
+// as a single token by the Parser and taken literally.
+//
+// Single quotes inside the given string are escaped by closing the quotes,
+// escaping the single quote and reopening the quotes, like:
+//
+//	'it'\''s'
+func Quote(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `'\''`) + `'`
+}
@@ -1,27 +1,200 @@
 package cmdline
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 )
 
+// testParser returns a Parser with a fixed environment and home directory.
+func testParser() *Parser {
+	env := map[string]string{
+		"NAME":  "value",
+		"SPACE": "a b",
+		"_x1":   "x",
+	}
+	return &Parser{
+		Getenv:  func(name string) string { return env[name] },
+		HomeDir: func() (string, error) { return "/home/user", nil },
+	}
+}
+
 func TestParse(t *testing.T) {
-	parser := NewParser()
+	parser := testParser()
+
+	cases := []struct {
+		input     string
+		pipelines []Pipeline
+	}{
+		{"cmd param1 param2", []Pipeline{{{CmdName: "cmd", Args: []string{"param1", "param2"}}}}},
+		{"cmd  param1 \t param2", []Pipeline{{{CmdName: "cmd", Args: []string{"param1", "param2"}}}}},
+		{"cmd  \"param 1\" \t 'param two'", []Pipeline{{{CmdName: "cmd", Args: []string{"param 1", "param two"}}}}},
+		{"cmd1 param1 param2 | cmd2 paramA", []Pipeline{{{CmdName: "cmd1", Args: []string{"param1", "param2"}}, {CmdName: "cmd2", Args: []string{"paramA"}}}}},
+		{"cmd1|cmd2", []Pipeline{{{CmdName: "cmd1"}, {CmdName: "cmd2"}}}},
+		{"", []Pipeline{}},
+		{"  \t ", []Pipeline{}},
+		// quotes and escapes
+		{`cmd --opt="a b" pre'fix 'post`, []Pipeline{{{CmdName: "cmd", Args: []string{"--opt=a b", "prefix post"}}}}},
+		{`cmd '' ""`, []Pipeline{{{CmdName: "cmd", Args: []string{"", ""}}}}},
+		{`cmd a\ b \'c\' \\ \|`, []Pipeline{{{CmdName: "cmd", Args: []string{"a b", "'c'", `\`, "|"}}}}},
+		{`cmd "a \"b\" \$c \d" 'e \f'`, []Pipeline{{{CmdName: "cmd", Args: []string{`a "b" $c \d`, `e \f`}}}}},
+		{`cmd "it's" 'say "hi"'`, []Pipeline{{{CmdName: "cmd", Args: []string{"it's", `say "hi"`}}}}},
+		{`cmd 'it'\''s'`, []Pipeline{{{CmdName: "cmd", Args: []string{"it's"}}}}},
+		{"cmd 'a | b' \"c && d\" '<e>'", []Pipeline{{{CmdName: "cmd", Args: []string{"a | b", "c && d", "<e>"}}}}},
+		{"cmd äöü 'ß'", []Pipeline{{{CmdName: "cmd", Args: []string{"äöü", "ß"}}}}},
+		// variables
+		{`cmd $NAME ${NAME}s "$NAME" '$NAME' $_x1`, []Pipeline{{{CmdName: "cmd", Args: []string{"value", "values", "value", "$NAME", "x"}}}}},
+		{`cmd $SPACE $UNDEFINED`, []Pipeline{{{CmdName: "cmd", Args: []string{"a b", ""}}}}},
+		{`cmd $ a$ $1 "$"`, []Pipeline{{{CmdName: "cmd", Args: []string{"$", "a$", "$1", "$"}}}}},
+		// home directory
+		{"cmd ~ ~/file a~ '~' ~user", []Pipeline{{{CmdName: "cmd", Args: []string{"/home/user", "/home/user/file", "a~", "~", "~user"}}}}},
+		// sequences
+		{"cmd1 && cmd2 a | cmd3", []Pipeline{{{CmdName: "cmd1"}}, {{CmdName: "cmd2", Args: []string{"a"}}, {CmdName: "cmd3"}}}},
+		{"cmd1&&cmd2", []Pipeline{{{CmdName: "cmd1"}}, {{CmdName: "cmd2"}}}},
+		// redirections
+		{"cmd < in > out", []Pipeline{{{CmdName: "cmd", StdinFile: "in", StdoutFile: "out"}}}},
+		{"cmd a >>out b", []Pipeline{{{CmdName: "cmd", Args: []string{"a", "b"}, StdoutFile: "out", AppendStdout: true}}}},
+		{"cmd1 <in | cmd2 >'out file'", []Pipeline{{{CmdName: "cmd1", StdinFile: "in"}, {CmdName: "cmd2", StdoutFile: "out file"}}}},
+	}
+	for _, tt := range cases {
+		pipelines, err := parser.Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(pipelines, tt.pipelines) {
+			t.Errorf("Parse(%q) == %+v, expected %+v", tt.input, pipelines, tt.pipelines)
+		}
+	}
+}
+
+func TestParseSyntaxError(t *testing.T) {
+	parser := testParser()
 
 	cases := []struct {
 		input string
-		cmd   []Cmd
+		pos   int
+	}{
+		{"| cmd", 0},
+		{"cmd |", 4},
+		{"cmd | | cmd2", 6},
+		{"cmd1 || cmd2", 6},
+		{"&& cmd", 0},
+		{"cmd &&", 4},
+		{"cmd & cmd2", 4},
+		{"cmd 'unterminated", 4},
+		{`cmd "unterminated`, 4},
+		{`cmd a"b'c`, 5},
+		{`cmd \`, 4},
+		{"cmd ${NAME", 4},
+		{"cmd ${1x}", 4},
+		{"cmd >", 4},
+		{"cmd > | cmd2", 4},
+		{"> out cmd", 0},
+		{"cmd >a >b", 7},
+		{"cmd <a <b", 7},
+		{"cmd1 | cmd2 < in", 12},
+		{"cmd1 > out | cmd2", 5},
+	}
+	for _, tt := range cases {
+		pipelines, err := parser.Parse(tt.input)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q) == %+v, %v, expected SyntaxError", tt.input, pipelines, err)
+			continue
+		}
+		if syntaxErr.Pos != tt.pos {
+			t.Errorf("Parse(%q) returned error at position %d, expected %d: %v", tt.input, syntaxErr.Pos, tt.pos, err)
+		}
+	}
+}
+
+func TestSyntaxErrorColumn(t *testing.T) {
+	err := &SyntaxError{CmdLine: "cmd 'äöü' |", Pos: 13, Msg: "Missing command after |"}
+	expected := "Syntax error in command line cmd 'äöü' | at column 11: Missing command after |"
+	if err.Error() != expected {
+		t.Errorf("Error() == %q, expected %q", err.Error(), expected)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	parser := testParser()
+	parser.Placeholders = FilePlaceholders("a.tex", "out")
+
+	cases := []string{
+		"simple",
+		"with whitespace",
+		"it's",
+		`"double" and 'single'`,
+		`$NAME ~ \ | && < > ${x}`,
+		"100% %f %%",
+		"",
+	}
+	for _, s := range cases {
+		quoted := Quote(s)
+		pipelines, err := parser.Parse(fmt.Sprintf("cmd %s", quoted))
+		if err != nil {
+			t.Errorf("Parse(%q) returned error %v", quoted, err)
+			continue
+		}
+		if args := pipelines[0][0].Args; len(args) != 1 || args[0] != s {
+			t.Errorf("Parse(Quote(%q)) == %q, expected %q", s, args, []string{s})
+		}
+	}
+}
+
+func TestParsePlaceholders(t *testing.T) {
+	parser := testParser()
+	parser.Placeholders = FilePlaceholders("/my dir/it's.tex", "/out")
+
+	cases := []struct {
+		input string
+		cmd   Cmd
+	}{
+		{"cmd %f", Cmd{CmdName: "cmd", Args: []string{"/my dir/it's.tex"}}},
+		{"cmd -outdir=%o %b.tex %d", Cmd{CmdName: "cmd", Args: []string{"-outdir=/out", "it's.tex", "/my dir"}}},
+		{`cmd "%f" "%b" %% 100%% %x % %`, Cmd{CmdName: "cmd", Args: []string{"/my dir/it's.tex", "it's", "%", "100%", "%x", "%", "%"}}},
+		// single quotes are taken literally
+		{`cmd '%f' '100%%' -o='%o'`, Cmd{CmdName: "cmd", Args: []string{"%f", "100%%", "-o=%o"}}},
+		{"cmd > %b.log", Cmd{CmdName: "cmd", StdoutFile: "it's.log"}},
+	}
+	for _, tt := range cases {
+		pipelines, err := parser.Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error %v", tt.input, err)
+			continue
+		}
+		if expected := []Pipeline{{tt.cmd}}; !reflect.DeepEqual(pipelines, expected) {
+			t.Errorf("Parse(%q) == %+v, expected %+v", tt.input, pipelines, expected)
+		}
+	}
+
+	// without placeholders percent signs are taken literally
+	parser.Placeholders = nil
+	pipelines, err := parser.Parse("date +%Y %% %f")
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+	if args := pipelines[0][0].Args; !reflect.DeepEqual(args, []string{"+%Y", "%%", "%f"}) {
+		t.Errorf("Parse without placeholders == %q, expected %q", args, []string{"+%Y", "%%", "%f"})
+	}
+}
+
+func TestWithFile(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
 	}{
-		{"cmd param1 param2", []Cmd{Cmd{"cmd", []string{"param1", "param2"}}}},
-		{"cmd  param1 \t param2", []Cmd{Cmd{"cmd", []string{"param1", "param2"}}}},
-		{"cmd  \"param 1\" \t 'param two'", []Cmd{Cmd{"cmd", []string{"param 1", "param two"}}}},
-		{"cmd1 param1 param2 | cmd2 paramA", []Cmd{Cmd{"cmd1", []string{"param1", "param2"}}, Cmd{"cmd2", []string{"paramA"}}}},
-		{"", []Cmd{}},
+		{"pdflatex", "pdflatex %f"},
+		{"latexmk -pdf %f", "latexmk -pdf %f"},
+		{"latexmk -jobname=%b -outdir=%o", "latexmk -jobname=%b -outdir=%o"},
+		{"viewer --dir %d", "viewer --dir %d %f"},
+		{"viewer %%f", "viewer %%f %f"},
 	}
 	for _, tt := range cases {
-		cmd := parser.Parse(tt.input)
-		if !reflect.DeepEqual(cmd, tt.cmd) {
-			t.Errorf("Parse(%q) == %q, expected %q", tt.input, cmd, tt.cmd)
+		if s := WithFile(tt.input); s != tt.expected {
+			t.Errorf("WithFile(%q) == %q, expected %q", tt.input, s, tt.expected)
 		}
 	}
 }
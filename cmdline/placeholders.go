@@ -0,0 +1,82 @@
+package cmdline
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Placeholders maps the placeholders in a command line to their values.
+//
+// The keys are the characters following the percent sign, like 'f' for the
+// placeholder %f. The placeholder %% always stands for a literal percent
+// sign. Unknown placeholders are left as they are. Inside single quotes
+// placeholders are not substituted.
+//
+// Placeholders are substituted into the words of the parsed command line.
+// Therefore their values are never split into multiple words or
+// interpreted as operators and need no quoting.
+type Placeholders map[rune]string
+
+// FilePlaceholders returns the Placeholders for the given file and output
+// directory:
+//
+//	%f  the file
+//	%d  the directory of the file
+//	%b  the base name of the file without extension
+//	%o  the output directory
+func FilePlaceholders(file string, outputDir string) Placeholders {
+	base := filepath.Base(file)
+	return Placeholders{
+		'f': file,
+		'd': filepath.Dir(file),
+		'b': strings.TrimSuffix(base, filepath.Ext(base)),
+		'o': outputDir,
+	}
+}
+
+// HasPlaceholder returns true if the given cmdLine contains at least one of
+// the given placeholders (given without the percent sign).
+func HasPlaceholder(cmdLine string, placeholders ...rune) bool {
+	for i := 0; i < len(cmdLine)-1; i++ {
+		if cmdLine[i] != '%' {
+			continue
+		}
+		next := rune(cmdLine[i+1])
+		for _, p := range placeholders {
+			if next == p {
+				return true
+			}
+		}
+		// skip the character following the percent sign, so that %% is not
+		// taken as the start of a placeholder
+		i++
+	}
+	return false
+}
+
+// WithFile returns the given cmdLine with the placeholder %f for the file
+// appended, unless the cmdLine already refers to the file via %f or %b.
+func WithFile(cmdLine string) string {
+	if HasPlaceholder(cmdLine, 'f', 'b') {
+		return cmdLine
+	}
+	return cmdLine + " %f"
+}
+
+// expand returns the value of the placeholder starting with the percent
+// sign at position i of the given string and the number of bytes the
+// placeholder occupies.
+//
+// If there are no Placeholders at all, the percent sign is returned as is.
+func (p Placeholders) expand(s string, i int) (string, int) {
+	if p == nil || i+1 >= len(s) {
+		return "%", 1
+	}
+	if s[i+1] == '%' {
+		return "%", 2
+	}
+	if value, ok := p[rune(s[i+1])]; ok {
+		return value, 2
+	}
+	return "%", 1
+}
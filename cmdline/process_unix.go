@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package cmdline
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup configures the given command to be started in its own
+// process group.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcess kills the given started command. If it runs in its own
+// process group, the whole process group is killed.
+func killProcess(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Setpgid {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		return
+	}
+	cmd.Process.Kill()
+}
@@ -0,0 +1,26 @@
+//go:build windows
+// +build windows
+
+package cmdline
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup configures the given command to be started in its own
+// process group.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// killProcess kills the given started command.
+//
+// Child processes of the command are not killed, since Windows has no
+// equivalent to killing a process group.
+func killProcess(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	cmd.Process.Kill()
+}
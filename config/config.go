@@ -1,6 +1,7 @@
 package config
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -9,28 +10,66 @@ import (
 	"os/user"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"poiu.de/brief/locale"
 )
 
 var (
-	defaultEditors         = []string{"nvim", "vim", "$VISUAL", "sensible-editor", "$EDITOR"}
-	defaultPdfCommands     = []string{"latexrun", "latexmk", "lualatex", "xelatex", "pdflatex"}
-	defaultPreviewCommands = []string{"mupdf", "zathura", "katarakt", "evince", "okular", "qpdfview", "skim", "SumatraPDF", "xpdf"}
+	defaultEditors            = []string{"nvim", "vim", "$VISUAL", "sensible-editor", "$EDITOR"}
+	defaultPdfCommands        = []string{"latexrun", "latexmk", "lualatex", "xelatex", "pdflatex"}
+	defaultPdfJoinCommands    = []string{"pdfunite"}
+	defaultImageToPdfCommands = []string{"magick", "convert"}
+	defaultPrintCommands      = []string{"lpr", "lp"}
+	defaultPreviewCommands    = []string{"mupdf", "zathura", "katarakt", "evince", "okular", "qpdfview", "skim", "SumatraPDF", "xpdf"}
+)
+
+// Kinds of external commands for which a timeout can be configured in
+// Config.CommandTimeouts.
+const (
+	TimeoutMarkup     = "markup"
+	TimeoutPdf        = "pdf"
+	TimeoutTypst      = "typst"
+	TimeoutPdfJoin    = "pdfjoin"
+	TimeoutImageToPdf = "imagetopdf"
+	TimeoutPreview    = "preview"
+	TimeoutPrint      = "print"
 )
 
 // Config contains the configuration for the brief application.
 // It can (and should) be prefilled via call to NewConfig() and can
 // (and should) be overriden via config file or command line flags.
 type Config struct {
-	Editor           string
-	TexTemplateDir   string
-	DocumentRoots    []string
-	AddressBook      string
-	SenderList       string
-	PdfCommand       string
-	PreviewCommand   string
-	FindCommand      string
-	ListerCommand    string
-	MarkupConverters map[string]string
+	Editor            string
+	TexTemplateDir    string
+	HtmlTemplateDir   string
+	TypstTemplateDir  string
+	DocumentRoots     []string
+	AddressBook       string
+	SenderList        string
+	BuildDir          string
+	OutputDir         string
+	OutputPattern     string
+	PdfCommand        string
+	PdfEngines        []string
+	PdfJoinCommand    string
+	TypstCommand      string
+	ImageToPdfCommand string
+	PreviewCommand    string
+	PrintCommand      string
+	FindCommand       string
+	ListerCommand     string
+	MarkupConverters  map[string]string
+	CommandTimeouts   map[string]time.Duration
+	Language          string
+	IndexFile         string
+	SearchIndexFile   string
+	UseSearchIndex    bool
+	SmtpHost          string
+	SmtpPort          int
+	SmtpUser          string
+	SmtpPassword      string
+	SmtpStartTLS      bool
 }
 
 // NewConfig creates a new Config with the default configuration.
@@ -59,6 +98,18 @@ func NewConfig() *Config {
 	}
 	c.TexTemplateDir = texTemplateDir
 
+	htmlTemplateDir, err := expandFileName("~/.config/brief/html-templates")
+	if err != nil {
+		log.Println(fmt.Errorf("Error expanding html template dir %s: %w", "~/.config/brief/html-templates", err))
+	}
+	c.HtmlTemplateDir = htmlTemplateDir
+
+	typstTemplateDir, err := expandFileName("~/.config/brief/typst-templates")
+	if err != nil {
+		log.Println(fmt.Errorf("Error expanding typst template dir %s: %w", "~/.config/brief/typst-templates", err))
+	}
+	c.TypstTemplateDir = typstTemplateDir
+
 	c.DocumentRoots = []string{"."}
 
 	addresBook, err := expandFileName("~/.config/brief/addressbook")
@@ -73,11 +124,39 @@ func NewConfig() *Config {
 	}
 	c.SenderList = senderList
 
+	// auxiliary files are written into this directory next to the letters
+	c.BuildDir = ".brief-build"
+
+	// the final artifacts are written next to the letters and named like
+	// them, unless configured otherwise, like "{date}_{to}_{subject}.pdf"
+	c.OutputDir = os.Getenv("BRIEF_OUTPUT_DIR")
+	c.OutputPattern = os.Getenv("BRIEF_OUTPUT_PATTERN")
+
 	pdfCommand, err := findExecutable(defaultPdfCommands)
 	if err != nil {
 		log.Println(fmt.Errorf("No default pdf-command found: %w", err))
 	}
 	c.PdfCommand = pdfCommand
+	// the other engines are tried if the preferred one fails
+	c.PdfEngines = findExecutables(defaultPdfCommands)
+
+	typstCommand, err := findDefaultTypstCommand()
+	if err != nil {
+		log.Println(fmt.Errorf("No default typst-command found: %w", err))
+	}
+	c.TypstCommand = typstCommand
+
+	pdfJoinCommand, err := findExecutable(defaultPdfJoinCommands)
+	if err != nil {
+		log.Println(fmt.Errorf("No default pdf-join-command found: %w", err))
+	}
+	c.PdfJoinCommand = pdfJoinCommand
+
+	imageToPdfCommand, err := findExecutable(defaultImageToPdfCommands)
+	if err != nil {
+		log.Println(fmt.Errorf("No default image-to-pdf-command found: %w", err))
+	}
+	c.ImageToPdfCommand = imageToPdfCommand
 
 	previewCommand, err := findExecutable(defaultPreviewCommands)
 	if err != nil {
@@ -85,6 +164,12 @@ func NewConfig() *Config {
 	}
 	c.PreviewCommand = previewCommand
 
+	printCommand, err := findExecutable(defaultPrintCommands)
+	if err != nil {
+		log.Println(fmt.Errorf("No default print-command found: %w", err))
+	}
+	c.PrintCommand = printCommand
+
 	findCommand, err := findDefaultFindCommand()
 	if err != nil {
 		log.Println(fmt.Errorf("No default find-command found: %w", err))
@@ -100,9 +185,52 @@ func NewConfig() *Config {
 	markupConverters := findDefaultMarkupConverters()
 	c.MarkupConverters = markupConverters
 
+	// The previewer runs until the user closes it and therefore has no
+	// timeout.
+	c.CommandTimeouts = map[string]time.Duration{
+		TimeoutMarkup:     1 * time.Minute,
+		TimeoutPdf:        5 * time.Minute,
+		TimeoutTypst:      2 * time.Minute,
+		TimeoutPdfJoin:    1 * time.Minute,
+		TimeoutImageToPdf: 1 * time.Minute,
+		TimeoutPrint:      1 * time.Minute,
+	}
+
+	c.Language = findDefaultLanguage()
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		log.Println(fmt.Errorf("Cannot determine cache directory for index file: %w", err))
+	} else {
+		c.IndexFile = filepath.Join(cacheDir, "brief", "index.json")
+		c.SearchIndexFile = filepath.Join(cacheDir, "brief", "search-index.json")
+	}
+
+	// SMTP settings for sending letters by email
+	c.SmtpHost = "localhost"
+	c.SmtpPort = 587
+	c.SmtpUser = os.Getenv("BRIEF_SMTP_USER")
+	c.SmtpPassword = os.Getenv("BRIEF_SMTP_PASSWORD")
+	c.SmtpStartTLS = true
+
 	return c
 }
 
+// CommandContext returns a context for executing an external command of
+// the given kind (like TimeoutPdf).
+//
+// The returned context is cancelled when the given context is cancelled or
+// the timeout configured for that kind of command has passed. If no timeout
+// (or a timeout <= 0) is configured, only the cancellation of the given
+// context applies.
+// The returned CancelFunc must be called after the command finished.
+func (c *Config) CommandContext(ctx context.Context, kind string) (context.Context, context.CancelFunc) {
+	if timeout := c.CommandTimeouts[kind]; timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
 // findExecutable iterates over a list of excutable names and tries whether
 // those can be found in the $PATH. The first executable that is found is
 // returned.
@@ -130,18 +258,64 @@ func findExecutable(executables []string) (string, error) {
 	return "", errors.New("No usable executable found")
 }
 
+// findExecutables returns all of the given executables that can be found in
+// the $PATH, in the given order.
+//
+// Environment variables are expanded as in findExecutable.
+func findExecutables(executables []string) []string {
+	found := []string{}
+	for _, executable := range executables {
+		executableName, err := findExecutable([]string{executable})
+		if err == nil {
+			found = append(found, executableName)
+		}
+	}
+	return found
+}
+
+// findDefaultTypstCommand returns the external command to use for
+// compiling Typst files into PDF files.
+//
+// This is typst, if it is installed. Its root directory is the directory
+// of the Typst file, so images (like the signature) are copied below it
+// before. The Typst file (%f) and the PDF file (%o) are appended to the
+// returned command.
+func findDefaultTypstCommand() (string, error) {
+	typst, err := findExecutable([]string{"typst"})
+	if err != nil {
+		return "", err
+	}
+	return typst + " compile", nil
+}
+
+// findDefaultFindCommand returns the external command to use for searching
+// .brf files.
+//
+// This is ripgrep, if it is installed. The search query and the document
+// roots are appended to the returned command.
+//
+// If ripgrep is not installed, an error is returned. In that case the built
+// in search is used.
 func findDefaultFindCommand() (string, error) {
-	//TODO: Real decision logic
-	return "rg", nil
+	rg, err := findExecutable([]string{"rg"})
+	if err != nil {
+		return "", err
+	}
+	return rg + " --line-number --ignore-case --fixed-strings --glob *.brf --", nil
 }
 
+// findDefaultListerCommand returns the external command to use for
+// selecting one of several items (like letters or addresses).
+//
+// This is fzf, if it is installed. If fzf is not installed, an error is
+// returned. In that case the built in selector is used.
 func findDefaultListerCommand() (string, error) {
-	//TODO: Real decision logic
-	return "fzf", nil
+	return findExecutable([]string{"fzf"})
 }
 
 // findDefaultMarkupConverters tries to find executables to convert content
-// in markup sections to LaTeX code.
+// in markup sections to LaTeX or HTML code. The target format is given via
+// the placeholder %t and the line width of plain text via %w.
 //
 // By default this registers either 'asciidoctor' or 'asciidoc' to convert
 // asciidoc content and 'pandoc' for all other markup.
@@ -152,21 +326,37 @@ func findDefaultMarkupConverters() map[string]string {
 	x := lookPaths("asciidoctor", "asciidoc", "pandoc")
 	if x["pandoc"] != "" {
 		if x["asciidoctor"] != "" {
-			m["asciidoc"] = "asciidoctor -b docbook5 - | pandoc -f docbook -t latex"
+			m["asciidoc"] = "asciidoctor -b docbook5 - | pandoc -f docbook -t %t -M lang=%l --columns=%w"
 		} else if x["asciidoc"] != "" {
-			m["asciidoc"] = "asciidoc -b docbook5 - | pandoc -f docbook -t latex"
+			m["asciidoc"] = "asciidoc -b docbook5 - | pandoc -f docbook -t %t -M lang=%l --columns=%w"
 		}
 	}
 	m["adoc"] = m["asciidoc"]
 
 	// pandoc as default for all other markups
 	if x["pandoc"] != "" {
-		m["*"] = "pandoc -f %m -t latex"
+		m["*"] = "pandoc -f %m -t %t -M lang=%l --columns=%w"
 	}
 
 	return m
 }
 
+// findDefaultLanguage returns the language code to use for letters that
+// don't specify a language themselves.
+//
+// It is derived from the environment variables $LC_ALL, $LC_MESSAGES and
+// $LANG (in this order). If none of them is set to a supported language,
+// German ("de") is used.
+func findDefaultLanguage() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if l, err := locale.Get(os.Getenv(env)); err == nil {
+			return l.Code
+		}
+	}
+
+	return "de"
+}
+
 // lookPaths tries to find the given executables in the current $PATH.
 //
 // The return value is a map with the given executable names as key.
@@ -0,0 +1,391 @@
+/*
+ * Package index maintains an index of all .brf files in the document roots
+ * to allow querying the archive of letters without parsing all .brf files
+ * again.
+ */
+package index
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"poiu.de/brief/address"
+	"poiu.de/brief/config"
+	"poiu.de/brief/letter"
+	"poiu.de/brief/locale"
+	"poiu.de/brief/parser"
+
+	"poiu.de/brief/utils"
+)
+
+// The version of the index file format. Index files with a different
+// version are discarded and rebuilt.
+const indexVersion = 2
+
+// Output status of an Entry
+const (
+	// No PDF file exists for the .brf file.
+	StatusMissing = "missing"
+	// The PDF file is older than the .brf file.
+	StatusStale = "stale"
+	// The PDF file is up to date.
+	StatusUpToDate = "ok"
+)
+
+// Entry contains the indexed data of a single .brf file.
+type Entry struct {
+	// The path of the .brf file.
+	File string `json:"file"`
+	// The modification time of the .brf file when it was indexed.
+	ModTime time.Time `json:"modTime"`
+	// The name of the recipient.
+	To string `json:"to"`
+	// The name of the sender as given in the FROM section.
+	From string `json:"from"`
+	// The date of the letter in ISO format. If the DATE section cannot be
+	// parsed, it contains the content of the DATE section as is.
+	Date string `json:"date"`
+	// The subject of the letter.
+	Subject string `json:"subject"`
+	// The tags of the letter as given in the TAGS section.
+	Tags []string `json:"tags"`
+	// The path of the PDF file of the letter (see letter.OutputFile). Empty
+	// if the OutputPattern cannot be expanded for the letter.
+	Output string `json:"output"`
+	// The status of the PDF file of the letter. One of StatusMissing,
+	// StatusStale or StatusUpToDate.
+	Status string `json:"status"`
+}
+
+// Index contains the indexed data of all .brf files in the document roots.
+type Index struct {
+	// The version of the index file format.
+	Version int `json:"version"`
+	// The indexed .brf files with their path as key.
+	Entries map[string]Entry `json:"entries"`
+	// The configured OutputDir and OutputPattern the Entries were indexed
+	// with, since they determine the output files.
+	OutputDir     string `json:"outputDir"`
+	OutputPattern string `json:"outputPattern"`
+}
+
+// Query specifies the criteria to filter the Entries of an Index.
+// Empty criteria match all Entries. Entries with a date that cannot be
+// parsed never match Since or Until.
+type Query struct {
+	// Case insensitive substring of the recipient.
+	To string
+	// Case insensitive substring of the sender.
+	From string
+	// The earliest date (inclusive) in ISO format.
+	Since string
+	// The latest date (inclusive) in ISO format.
+	Until string
+	// Tags that must all be set on an Entry.
+	Tags []string
+}
+
+// New creates a new empty Index.
+func New() *Index {
+	return &Index{Version: indexVersion, Entries: make(map[string]Entry)}
+}
+
+// Load reads the Index from the given file.
+//
+// If the file does not exist or has an incompatible version, an empty
+// Index is returned.
+func Load(indexFile string) (*Index, error) {
+	content, err := os.ReadFile(indexFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return New(), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error reading index file %s: %w", indexFile, err)
+	}
+
+	idx := New()
+	err = json.Unmarshal(content, idx)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid index file %s: %w", indexFile, err)
+	}
+	if idx.Version != indexVersion || idx.Entries == nil {
+		return New(), nil
+	}
+
+	return idx, nil
+}
+
+// Save writes this Index to the given file.
+func (idx *Index) Save(indexFile string) error {
+	err := os.MkdirAll(filepath.Dir(indexFile), 0755)
+	if err != nil {
+		return fmt.Errorf("Cannot create directory for index file %s: %w", indexFile, err)
+	}
+
+	content, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("Cannot serialize index: %w", err)
+	}
+
+	err = utils.WriteFileAtomic(indexFile, content)
+	if err != nil {
+		return fmt.Errorf("Error writing index file %s: %w", indexFile, err)
+	}
+	return nil
+}
+
+// Update brings this Index up to date with the .brf files in the given
+// document roots.
+//
+// Only .brf files that were modified since they were last indexed are read
+// again, unless the configured OutputDir or OutputPattern changed.
+// Entries of .brf files that don't exist anymore are removed.
+// The output status of all Entries is refreshed.
+// .brf files that cannot be read are logged and skipped.
+//
+// Hidden directories (starting with a dot) are skipped.
+func (idx *Index) Update(roots []string, cfg *config.Config) error {
+	var addresses letter.Addresses
+	if cfg.AddressBook != "" {
+		if _, err := os.Stat(cfg.AddressBook); err == nil {
+			addresses.AddressBook, err = address.ReadFromFile(cfg.AddressBook)
+			if err != nil {
+				return fmt.Errorf("Error reading address book from %s: %w", cfg.AddressBook, err)
+			}
+		}
+	}
+	// the sender list is only needed to expand placeholders, so letters
+	// are indexed without it if it cannot be read
+	if senders, err := address.ReadFromFile(cfg.SenderList); err == nil {
+		addresses.Senders = senders
+	}
+
+	reindex := idx.OutputDir != cfg.OutputDir || idx.OutputPattern != cfg.OutputPattern
+	idx.OutputDir = cfg.OutputDir
+	idx.OutputPattern = cfg.OutputPattern
+
+	found := make(map[string]bool)
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(path) != ".brf" {
+				return nil
+			}
+
+			absPath, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			found[absPath] = true
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			entry, exists := idx.Entries[absPath]
+			if !exists || reindex || !entry.ModTime.Equal(info.ModTime()) {
+				entry, err = newEntry(absPath, info.ModTime(), cfg, addresses)
+				if err != nil {
+					log.Printf("Skipping %s: %v", path, err)
+					delete(found, absPath)
+					return nil
+				}
+			}
+			entry.Status = outputStatus(entry)
+			idx.Entries[absPath] = entry
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("Error indexing %s: %w", root, err)
+		}
+	}
+
+	for file := range idx.Entries {
+		if !found[file] {
+			delete(idx.Entries, file)
+		}
+	}
+
+	return nil
+}
+
+// Find returns all Entries of this Index that match the given Query, sorted
+// by date and file name.
+func (idx *Index) Find(q Query) []Entry {
+	entries := make([]Entry, 0)
+	for _, e := range idx.Entries {
+		if q.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Date != entries[j].Date {
+			return entries[i].Date < entries[j].Date
+		}
+		return entries[i].File < entries[j].File
+	})
+
+	return entries
+}
+
+// matches checks whether the given Entry matches this Query.
+func (q Query) matches(e Entry) bool {
+	if q.To != "" && !strings.Contains(strings.ToLower(e.To), strings.ToLower(q.To)) {
+		return false
+	}
+	if q.From != "" && !strings.Contains(strings.ToLower(e.From), strings.ToLower(q.From)) {
+		return false
+	}
+	// entries with an unparsable date cannot be compared to dates
+	if (q.Since != "" || q.Until != "") && !isISODate(e.Date) {
+		return false
+	}
+	if q.Since != "" && e.Date < q.Since {
+		return false
+	}
+	if q.Until != "" && e.Date > q.Until {
+		return false
+	}
+	for _, tag := range q.Tags {
+		found := false
+		for _, t := range e.Tags {
+			if strings.EqualFold(t, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// isISODate returns true if the given date is in ISO format.
+func isISODate(date string) bool {
+	_, err := time.Parse("2006-01-02", date)
+	return err == nil
+}
+
+// newEntry reads the given .brf file and creates an Entry for it.
+//
+// Placeholders in the subject are expanded and the output file is
+// determined like for rendering if the .brf file is a valid letter.
+// Otherwise the subject is indexed as is and only the placeholders name,
+// date, to, from and subject are supported in the OutputPattern.
+// The date of a valid letter is the date it is rendered with, so that it
+// matches the date in the output file. Otherwise dates relative to today
+// (like 'today' or '+3d') are resolved relative to the modification time
+// of the .brf file.
+func newEntry(brfFile string, modTime time.Time, cfg *config.Config, addresses letter.Addresses) (Entry, error) {
+	brf, err := parser.ReadBrfFile(brfFile)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e := Entry{File: brfFile, ModTime: modTime}
+	e.From = sectionValue(brf, "FROM", " ")
+	e.Subject = sectionValue(brf, "SUBJECT", " ")
+	var l *letter.Letter
+	if addresses.Senders != nil {
+		if valid, err := letter.NewWithAddresses(brfFile, brf, cfg, addresses); err == nil {
+			l = valid
+			e.Subject = sectionValue(l.Brf, "SUBJECT", " ")
+		}
+	}
+
+	to := parser.TrimSurroundingEmptyLines(brf.Sections["TO"])
+	if len(to) > 0 {
+		e.To = strings.TrimSpace(to[0])
+		if recipient, ok := addresses.AddressBook[e.To]; ok && len(to) == 1 {
+			e.To = recipient.Value("name")
+		}
+	}
+
+	loc, err := locale.Get(cfg.Language)
+	if language := sectionValue(brf, "LANGUAGE", ""); language != "" {
+		loc, err = locale.Get(language)
+	}
+	e.Date = sectionValue(brf, "DATE", " ")
+	if l != nil {
+		if l.DateText == "" {
+			e.Date = l.Date.Format("2006-01-02")
+		}
+	} else if err == nil {
+		if date, err := letter.ParseDate(e.Date, modTime, loc); err == nil {
+			e.Date = date.Format("2006-01-02")
+		}
+	}
+
+	e.Tags = make([]string, 0)
+	for _, line := range brf.Sections["TAGS"] {
+		for _, tag := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			e.Tags = append(e.Tags, tag)
+		}
+	}
+
+	values := map[string]string{}
+	if l != nil {
+		values = l.OutputValues()
+	} else {
+		values = map[string]string{
+			"name":    strings.TrimSuffix(filepath.Base(brfFile), filepath.Ext(brfFile)),
+			"date":    e.Date,
+			"to":      e.To,
+			"from":    e.From,
+			"subject": e.Subject,
+		}
+	}
+	e.Output, err = letter.OutputFile(brfFile, values, "pdf", cfg)
+	if err != nil {
+		log.Printf("No output file for %s: %v", brfFile, err)
+	}
+
+	return e, nil
+}
+
+// sectionValue returns the lines of the given section of the given Brf
+// joined by sep with surrounding whitespace removed.
+func sectionValue(brf parser.Brf, section string, sep string) string {
+	lines := parser.TrimSurroundingEmptyLines(brf.Sections[section])
+	trimmed := make([]string, len(lines))
+	for i, l := range lines {
+		trimmed[i] = strings.TrimSpace(l)
+	}
+	return strings.Join(trimmed, sep)
+}
+
+// outputStatus returns the status of the PDF file for the .brf file of the
+// given Entry.
+func outputStatus(e Entry) string {
+	brfInfo, err := os.Stat(e.File)
+	if err != nil {
+		return StatusMissing
+	}
+	pdfInfo, err := os.Stat(e.Output)
+	if err != nil {
+		return StatusMissing
+	}
+	if pdfInfo.ModTime().Before(brfInfo.ModTime()) {
+		return StatusStale
+	}
+	return StatusUpToDate
+}
@@ -0,0 +1,150 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"poiu.de/brief/config"
+)
+
+func TestUpdateAndFind(t *testing.T) {
+	root := t.TempDir()
+	cfg := &config.Config{Language: "de"}
+
+	writeBrf := func(name, content string) string {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tax := writeBrf("tax.brf", ".FROM\nme\n.TO\nFinanzamt Berlin\nPostfach 1\n.DATE\n2025-03-01\n.SUBJECT\nSteuer\n.TAGS\ntax, 2024\n")
+	writeBrf("rent.brf", ".FROM\nme\n.TO\nVermieter\n.DATE\n2024-12-01\n.SUBJECT\nMiete\n")
+	writeBrf("note.brf", ".FROM\nme\n.TO\nNachbarn\n.DATE\nim Mai 2024\n")
+	// unreadable files are skipped
+	if err := os.Symlink(filepath.Join(root, "missing"), filepath.Join(root, "broken.brf")); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := New()
+	if err := idx.Update([]string{root}, cfg); err != nil {
+		t.Fatalf("Update() returned error %v", err)
+	}
+
+	cases := []struct {
+		query Query
+		files []string
+	}{
+		{Query{}, []string{"rent.brf", "tax.brf", "note.brf"}},
+		{Query{To: "finanzamt"}, []string{"tax.brf"}},
+		{Query{Since: "2025-01-01"}, []string{"tax.brf"}},
+		{Query{Until: "2025-01-01"}, []string{"rent.brf"}},
+		{Query{Tags: []string{"TAX"}}, []string{"tax.brf"}},
+		{Query{Tags: []string{"tax", "other"}}, []string{}},
+	}
+	for _, tt := range cases {
+		files := make([]string, 0)
+		for _, e := range idx.Find(tt.query) {
+			files = append(files, filepath.Base(e.File))
+		}
+		if !reflect.DeepEqual(files, tt.files) {
+			t.Errorf("Find(%+v) == %q, expected %q", tt.query, files, tt.files)
+		}
+	}
+
+	// modified files are read again, deleted ones removed
+	writeBrf("tax.brf", ".FROM\nme\n.TO\nFinanzamt Köln\n.DATE\n2025-03-01\n")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(tax, later, later); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(root, "rent.brf"))
+	os.Remove(filepath.Join(root, "note.brf"))
+
+	if err := idx.Update([]string{root}, cfg); err != nil {
+		t.Fatalf("Update() returned error %v", err)
+	}
+	entries := idx.Find(Query{})
+	if len(entries) != 1 || entries[0].To != "Finanzamt Köln" || entries[0].Status != StatusMissing {
+		t.Errorf("Unexpected entries after update: %+v", entries)
+	}
+}
+
+func TestOutputStatus(t *testing.T) {
+	root := t.TempDir()
+	configDir := t.TempDir()
+	senderList := filepath.Join(configDir, "senders")
+	addressBook := filepath.Join(configDir, "addresses")
+	if err := os.WriteFile(senderList, []byte("address: me\nname: Max Mustermann\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(addressBook, []byte("address: fa\nname: Finanzamt\ncity: Berlin\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "tax.brf"), []byte(".FROM\nme\n.TO\nfa\n.DATE\n2025-03-01\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Language: "de", SenderList: senderList, AddressBook: addressBook, OutputPattern: "{date}_{from}_{to.city}"}
+
+	// the output file is named like when rendering the letter
+	idx := New()
+	if err := idx.Update([]string{root}, cfg); err != nil {
+		t.Fatalf("Update() returned error %v", err)
+	}
+	pdfFile := filepath.Join(root, "2025-03-01_Max_Mustermann_Berlin.pdf")
+	entries := idx.Find(Query{})
+	if len(entries) != 1 || entries[0].Output != pdfFile || entries[0].Status != StatusMissing {
+		t.Fatalf("Unexpected entries before rendering: %+v", entries)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.WriteFile(pdfFile, []byte("pdf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(pdfFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Update([]string{root}, cfg); err != nil {
+		t.Fatalf("Update() returned error %v", err)
+	}
+	if entries := idx.Find(Query{}); len(entries) != 1 || entries[0].Status != StatusUpToDate {
+		t.Errorf("Unexpected entries after rendering: %+v", entries)
+	}
+
+	// a changed output pattern updates the output files
+	cfg.OutputPattern = "{name}_{to.city}"
+	if err := idx.Update([]string{root}, cfg); err != nil {
+		t.Fatalf("Update() returned error %v", err)
+	}
+	if entries := idx.Find(Query{}); len(entries) != 1 || entries[0].Output != filepath.Join(root, "tax_Berlin.pdf") || entries[0].Status != StatusMissing {
+		t.Errorf("Unexpected entries after changing the output pattern: %+v", entries)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	indexFile := filepath.Join(dir, "cache", "index.json")
+
+	idx := New()
+	idx.Entries["/letters/a.brf"] = Entry{File: "/letters/a.brf", Subject: "Invoice", Tags: []string{"tax"}}
+	for i := 0; i < 2; i++ {
+		if err := idx.Save(indexFile); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+	}
+
+	loaded, err := Load(indexFile)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Entries, idx.Entries) {
+		t.Errorf("Load() == %+v, expected %+v", loaded.Entries, idx.Entries)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(indexFile)); len(entries) != 1 {
+		t.Errorf("Save() left %v, expected only the index file", entries)
+	}
+}
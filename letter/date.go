@@ -0,0 +1,159 @@
+package letter
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"poiu.de/brief/locale"
+)
+
+var (
+	// Regex for a relative date like "+3d" or "-2w"
+	patternRelativeDate *regexp.Regexp = regexp.MustCompile(`^([+-]\d+)\s*([dwmy])$`)
+	// Regex for a date referring to the next weekday like "next monday"
+	patternNextWeekday *regexp.Regexp = regexp.MustCompile(`^(?:next|nächste[nr]?)\s+(\S+)$`)
+	// Regex for a date with the name of the month like "3. März 2020" or
+	// "March 3, 2020"
+	patternDayMonthYear *regexp.Regexp = regexp.MustCompile(`^(\d{1,2})\.?\s+(\S+)\s+(\d{4})$`)
+	patternMonthDayYear *regexp.Regexp = regexp.MustCompile(`^(\S+)\s+(\d{1,2}),?\s+(\d{4})$`)
+
+	// Keywords for dates relative to today (in days)
+	relativeDateKeywords = map[string]int{
+		"today":     0,
+		"heute":     0,
+		"tomorrow":  1,
+		"morgen":    1,
+		"yesterday": -1,
+		"gestern":   -1,
+	}
+)
+
+// Now returns the current date.
+//
+// If the environment variable SOURCE_DATE_EPOCH is set, the date given by
+// that variable (in seconds since the epoch) is returned instead to allow
+// reproducible builds.
+func Now() time.Time {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err == nil {
+			return time.Unix(seconds, 0).UTC()
+		}
+	}
+
+	return time.Now()
+}
+
+// ParseDate parses the given value of a DATE section into a date.
+//
+// Supported are
+//   - an empty string or "today" for the given date now,
+//   - "tomorrow" and "yesterday",
+//   - ISO dates like "2020-03-03",
+//   - relative dates like "+3d", "-1w", "+1m" or "+1y" (days, weeks, months
+//     or years relative to now),
+//   - the next weekday like "next monday",
+//   - dates in the short and long format of the given Locale, like
+//     "03.03.2020" or "3. März 2020".
+//
+// Keywords and names of weekdays and months may be given in English or in
+// the language of the given Locale.
+func ParseDate(value string, now time.Time, loc *locale.Locale) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	v := strings.ToLower(strings.TrimSpace(value))
+
+	if v == "" {
+		return today, nil
+	}
+
+	if days, ok := relativeDateKeywords[v]; ok {
+		return today.AddDate(0, 0, days), nil
+	}
+
+	if m := patternRelativeDate.FindStringSubmatch(v); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "d":
+			return today.AddDate(0, 0, n), nil
+		case "w":
+			return today.AddDate(0, 0, 7*n), nil
+		case "m":
+			return today.AddDate(0, n, 0), nil
+		default:
+			return today.AddDate(n, 0, 0), nil
+		}
+	}
+
+	if m := patternNextWeekday.FindStringSubmatch(v); m != nil {
+		weekday, ok := findWeekday(m[1], loc)
+		if !ok {
+			return time.Time{}, fmt.Errorf("Invalid date %s. Unknown weekday %s", value, m[1])
+		}
+		days := (int(weekday) - int(today.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return today.AddDate(0, 0, days), nil
+	}
+
+	for _, layout := range []string{"2006-01-02", shortDateLayout(loc)} {
+		if t, err := time.ParseInLocation(layout, v, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	var day, month, year string
+	if m := patternDayMonthYear.FindStringSubmatch(v); m != nil {
+		day, month, year = m[1], m[2], m[3]
+	} else if m := patternMonthDayYear.FindStringSubmatch(v); m != nil {
+		day, month, year = m[2], m[1], m[3]
+	}
+	if m, ok := findMonth(month, loc); ok {
+		d, _ := strconv.Atoi(day)
+		y, _ := strconv.Atoi(year)
+		t := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+		if t.Day() == d {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Invalid date %s. Use an ISO date like 2020-03-31, 'today', a relative date like +3d or 'next monday'", value)
+}
+
+// shortDateLayout returns the layout for time.Parse matching the short date
+// format of the given Locale.
+func shortDateLayout(loc *locale.Locale) string {
+	return strings.NewReplacer(
+		"{day:02}", "02",
+		"{day}", "2",
+		"{month:02}", "01",
+		"{month}", "1",
+		"{year}", "2006",
+	).Replace(loc.ShortDateFormat)
+}
+
+// findWeekday returns the weekday with the given (case insensitive) name
+// in English or the language of the given Locale.
+func findWeekday(name string, loc *locale.Locale) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(name, d.String()) || strings.EqualFold(name, loc.Weekdays[d]) {
+			return d, true
+		}
+	}
+	return time.Sunday, false
+}
+
+// findMonth returns the month with the given (case insensitive) name in
+// English or the language of the given Locale.
+func findMonth(name string, loc *locale.Locale) (time.Month, bool) {
+	for m := time.January; m <= time.December; m++ {
+		if strings.EqualFold(name, m.String()) || strings.EqualFold(name, loc.Months[m-1]) {
+			return m, true
+		}
+	}
+	return time.January, false
+}
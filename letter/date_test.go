@@ -0,0 +1,91 @@
+package letter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"poiu.de/brief/config"
+	"poiu.de/brief/locale"
+	"poiu.de/brief/parser"
+)
+
+func TestParseDate(t *testing.T) {
+	de, _ := locale.Get("de")
+	// a Tuesday
+	now := time.Date(2020, time.March, 3, 14, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		input  string
+		output string
+	}{
+		{"", "2020-03-03"},
+		{"today", "2020-03-03"},
+		{"Morgen", "2020-03-04"},
+		{"2021-12-24", "2021-12-24"},
+		{"+3d", "2020-03-06"},
+		{"-1w", "2020-02-25"},
+		{"+1m", "2020-04-03"},
+		{"next monday", "2020-03-09"},
+		{"nächsten Dienstag", "2020-03-10"},
+		{"24.12.2021", "2021-12-24"},
+		{"24. Dezember 2021", "2021-12-24"},
+		{"December 24, 2021", "2021-12-24"},
+	}
+	for _, tt := range cases {
+		d, err := ParseDate(tt.input, now, de)
+		if err != nil {
+			t.Errorf("ParseDate(%q) returned error %v", tt.input, err)
+		} else if d.Format("2006-01-02") != tt.output {
+			t.Errorf("ParseDate(%q) == %s, expected %s", tt.input, d.Format("2006-01-02"), tt.output)
+		}
+	}
+
+	for _, invalid := range []string{"someday", "31. Februar 2020", "next moonday"} {
+		if _, err := ParseDate(invalid, now, de); err == nil {
+			t.Errorf("ParseDate(%q) should return an error", invalid)
+		}
+	}
+}
+
+func TestNowSourceDateEpoch(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1583193600")
+	if d := Now().Format("2006-01-02"); d != "2020-03-03" {
+		t.Errorf("Now() == %s, expected 2020-03-03", d)
+	}
+}
+
+func TestFreeTextDate(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1583193600")
+	dir := t.TempDir()
+	senderList := filepath.Join(dir, "senders")
+	if err := os.WriteFile(senderList, []byte("address: me\nname: Max Mustermann\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{SenderList: senderList, Language: "de"}
+
+	cases := []struct {
+		date     string
+		rendered string
+	}{
+		{"24.12.2021", "24. Dezember 2021"},
+		{"Berlin, den 3. März 2024", "Berlin, den 3. März 2024"},
+		{"im Mai 2024", "im Mai 2024"},
+	}
+
+	for _, c := range cases {
+		brf := parser.CreateBrf(parser.BrfLines{".FROM", "me", ".TO", "Erika Mustermann", ".DATE", c.date, ".CONTENT", "Datum: ${date}"})
+		l, err := New(filepath.Join(dir, "a.brf"), brf, cfg)
+		if err != nil {
+			t.Errorf("New() for DATE %q failed: %v", c.date, err)
+			continue
+		}
+		if date := l.Brf.Sections["DATE"]; len(date) != 1 || date[0] != c.rendered {
+			t.Errorf("DATE section for %q == %q, expected %q", c.date, date, c.rendered)
+		}
+		if content := l.Brf.Sections["CONTENT"]; len(content) != 1 || content[0] != "Datum: "+c.rendered {
+			t.Errorf("CONTENT section for %q == %q, expected %q", c.date, content, "Datum: "+c.rendered)
+		}
+	}
+}
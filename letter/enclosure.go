@@ -0,0 +1,84 @@
+package letter
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"poiu.de/brief/parser"
+)
+
+// Enclosure is a file that is listed in the ENCLOSURES section of a .brf
+// file and appended to the generated PDF file.
+type Enclosure struct {
+	// The path of the enclosed file. Relative paths in the .brf file are
+	// resolved relative to the directory of the .brf file.
+	File string
+	// The description of the enclosure as shown in the letter.
+	Description string
+}
+
+// IsImage returns true if the enclosed file is an image (instead of a PDF
+// file) that needs to be converted before it can be appended to a PDF
+// file.
+func (e Enclosure) IsImage() bool {
+	switch strings.ToLower(filepath.Ext(e.File)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".tif", ".tiff", ".bmp":
+		return true
+	default:
+		return false
+	}
+}
+
+// IsPdf returns true if the enclosed file is a PDF file.
+func (e Enclosure) IsPdf() bool {
+	return strings.ToLower(filepath.Ext(e.File)) == ".pdf"
+}
+
+// Enclosures returns the enclosures listed in the ENCLOSURES section of
+// this Letter.
+//
+// Each non-empty line in the ENCLOSURES section specifies a single file. An
+// optional description can be given after a pipe symbol, like
+//
+//	contract.pdf | Rental contract
+//
+// If no description is given, the file name without extension is used.
+func (l *Letter) Enclosures() []Enclosure {
+	enclosures := make([]Enclosure, 0)
+	for _, line := range parser.TrimSurroundingEmptyLines(l.Brf.Sections["ENCLOSURES"]) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		s := strings.SplitN(line, "|", 2)
+		file := strings.TrimSpace(s[0])
+		var description string
+		if len(s) == 2 {
+			description = strings.TrimSpace(s[1])
+		}
+		if description == "" {
+			description = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(l.BrfFile), file)
+		}
+
+		enclosures = append(enclosures, Enclosure{File: file, Description: description})
+	}
+
+	return enclosures
+}
+
+// EnclosureLines returns the numbered descriptions of all enclosures of
+// this Letter, like "Anlage 1: Mietvertrag" or "Enclosure 1: Rental
+// contract", depending on the language of this Letter.
+func (l *Letter) EnclosureLines() []string {
+	enclosures := l.Enclosures()
+	lines := make([]string, len(enclosures))
+	for i, e := range enclosures {
+		lines[i] = fmt.Sprintf("%s %d: %s", l.Locale.Label("enclosure"), i+1, e.Description)
+	}
+	return lines
+}
@@ -0,0 +1,97 @@
+package letter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"poiu.de/brief/locale"
+	"poiu.de/brief/parser"
+)
+
+func TestEnclosures(t *testing.T) {
+	brfFile := filepath.Join("letters", "2020", "a.brf")
+	cases := []struct {
+		section  parser.BrfLines
+		expected []Enclosure
+	}{
+		{nil, []Enclosure{}},
+		{parser.BrfLines{"", "contract.pdf", "", "  "}, []Enclosure{
+			{File: filepath.Join("letters", "2020", "contract.pdf"), Description: "contract"},
+		}},
+		{parser.BrfLines{"scans/invoice.png | Invoice of March", "/abs/path/offer.pdf|"}, []Enclosure{
+			{File: filepath.Join("letters", "2020", "scans", "invoice.png"), Description: "Invoice of March"},
+			{File: "/abs/path/offer.pdf", Description: "offer"},
+		}},
+	}
+
+	for _, c := range cases {
+		l := &Letter{BrfFile: brfFile, Brf: parser.Brf{Sections: map[string]parser.BrfLines{}}}
+		if c.section != nil {
+			l.Brf.Sections["ENCLOSURES"] = c.section
+		}
+		enclosures := l.Enclosures()
+		if !reflect.DeepEqual(enclosures, c.expected) {
+			t.Errorf("Enclosures() for %q == %+v, expected %+v", c.section, enclosures, c.expected)
+		}
+	}
+}
+
+func TestEnclosureLines(t *testing.T) {
+	de, _ := locale.Get("de")
+	en, _ := locale.Get("en")
+	section := parser.BrfLines{"contract.pdf | Mietvertrag", "scan.png"}
+
+	cases := []struct {
+		loc      *locale.Locale
+		expected []string
+	}{
+		{de, []string{"Anlage 1: Mietvertrag", "Anlage 2: scan"}},
+		{en, []string{"Enclosure 1: Mietvertrag", "Enclosure 2: scan"}},
+	}
+
+	for _, c := range cases {
+		l := &Letter{BrfFile: "a.brf", Locale: c.loc, Brf: parser.Brf{Sections: map[string]parser.BrfLines{"ENCLOSURES": section}}}
+		lines := l.EnclosureLines()
+		if !reflect.DeepEqual(lines, c.expected) {
+			t.Errorf("EnclosureLines() == %q, expected %q", lines, c.expected)
+		}
+	}
+}
+
+func TestValidateEnclosures(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "contract.pdf"), []byte("pdf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("txt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		section parser.BrfLines
+		problem string
+	}{
+		{parser.BrfLines{"contract.pdf"}, ""},
+		{parser.BrfLines{"contract.pdf", "missing.pdf | Missing"}, "Enclosure " + filepath.Join(dir, "missing.pdf") + " cannot be read"},
+		{parser.BrfLines{"missing.png"}, "Enclosure " + filepath.Join(dir, "missing.png") + " cannot be read"},
+		{parser.BrfLines{"notes.txt | Notes"}, "Unsupported enclosure " + filepath.Join(dir, "notes.txt")},
+	}
+
+	for _, c := range cases {
+		l := &Letter{BrfFile: filepath.Join(dir, "a.brf"), Brf: parser.Brf{Sections: map[string]parser.BrfLines{"ENCLOSURES": c.section}}}
+		err := l.Validate()
+		if c.problem == "" {
+			if err != nil {
+				t.Errorf("Validate() for %q failed: %v", c.section, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.problem) {
+			t.Errorf("Validate() for %q == %v, expected error containing %q", c.section, err, c.problem)
+		}
+	}
+}
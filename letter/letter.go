@@ -0,0 +1,332 @@
+/*
+ * Package letter combines a parsed .brf file with the addresses of its
+ * sender and recipient and the variables that can be referred to by
+ * placeholders inside the .brf file.
+ */
+package letter
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"poiu.de/brief/address"
+	"poiu.de/brief/config"
+	"poiu.de/brief/locale"
+	"poiu.de/brief/parser"
+)
+
+// Letter is a .brf file with all references to the sender list and the
+// address book resolved and all placeholders expanded.
+type Letter struct {
+	// The .brf file this Letter was read from.
+	BrfFile string
+	// The content of the .brf file with all placeholders expanded.
+	Brf parser.Brf
+	// The sender of this letter as referenced in the FROM section.
+	Sender address.Address
+	// The recipient of this letter.
+	Recipient address.Address
+	// The variables that are available as placeholders inside the .brf file.
+	Vars map[string]string
+	// The language specific settings for this letter.
+	Locale *locale.Locale
+	// The date of this letter as given in the DATE section.
+	Date time.Time
+	// The DATE section as given if it is a free text that cannot be parsed
+	// as a date (like "im Mai 2024"). Date is the current date then.
+	DateText string
+	// The signature image of the sender. Empty if the sender has no
+	// signature image or the letter opts out of it.
+	SignatureFile string
+}
+
+// Addresses contains the sender list and the address book Letters are
+// created with. See NewWithAddresses.
+type Addresses struct {
+	// The entries of the sender list by their name.
+	Senders map[string]address.Address
+	// The entries of the address book by their name. Nil if there is no
+	// address book.
+	AddressBook map[string]address.Address
+}
+
+// Load reads the given .brf file and creates a Letter from it.
+func Load(brfFile string, cfg *config.Config) (*Letter, error) {
+	brf, err := parser.ReadBrfFile(brfFile)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid brf source file: %w", err)
+	}
+
+	return New(brfFile, brf, cfg)
+}
+
+// New creates a Letter from the given Brf that was read from brfFile.
+//
+// The sender is looked up in the configured sender list by the name given
+// in the FROM section.
+//
+// If the TO section consists of a single line that names an entry in the
+// configured address book, that entry is used as the recipient and the TO
+// section is replaced by the postal address of that entry. Otherwise the
+// first line of the TO section is used as the recipients 'name' and the
+// remaining lines as its 'postal' address.
+//
+// Afterwards all placeholders in the Brf are expanded.
+func New(brfFile string, brf parser.Brf, cfg *config.Config) (*Letter, error) {
+	return newLetter(brfFile, brf, cfg, nil, nil)
+}
+
+// NewWithAddresses creates a Letter like New, but uses the given Addresses
+// instead of reading the configured sender list and address book.
+//
+// This is meant for creating many Letters at once, like when indexing all
+// .brf files.
+func NewWithAddresses(brfFile string, brf parser.Brf, cfg *config.Config, addresses Addresses) (*Letter, error) {
+	return newLetter(brfFile, brf, cfg, &addresses, nil)
+}
+
+// NewFor creates a Letter from the given Brf that was read from brfFile,
+// but sends it to the given recipient instead of the one specified in the
+// TO section.
+//
+// This is meant for serial letters where the same .brf file is sent to
+// multiple recipients. Therefore the TO section should either be empty (in
+// which case it is replaced by the postal address of the recipient) or
+// refer to the recipients fields via placeholders like ${to.name}.
+func NewFor(brfFile string, brf parser.Brf, cfg *config.Config, recipient address.Address) (*Letter, error) {
+	return newLetter(brfFile, brf, cfg, nil, &recipient)
+}
+
+// newLetter creates a Letter as described in New and NewFor. If addresses
+// is nil, the configured sender list and address book are read. If
+// recipient is nil, the recipient is determined by the TO section of the
+// given Brf.
+func newLetter(brfFile string, brf parser.Brf, cfg *config.Config, addresses *Addresses, recipient *address.Address) (*Letter, error) {
+	l := &Letter{BrfFile: brfFile}
+
+	var err error
+	if addresses == nil {
+		addresses = &Addresses{}
+		addresses.Senders, err = address.ReadFromFile(cfg.SenderList)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading sender list from %s: %w", cfg.SenderList, err)
+		}
+		if recipient == nil {
+			addresses.AddressBook, err = readAddressBook(cfg)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	fromAddress, err := parser.GetSingleValue(brf.Sections["FROM"])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid from-address name %s: %w", brf.Sections["FROM"], err)
+	}
+
+	sender, ok := addresses.Senders[fromAddress]
+	if !ok {
+		return nil, fmt.Errorf("Unknown from-address %s. Not contained in sender list %s", fromAddress, cfg.SenderList)
+	}
+	l.Sender = sender
+
+	// a given recipient replaces the TO section, unless the TO section
+	// refers to the recipient via placeholders
+	replaceTo := recipient != nil && len(parser.TrimSurroundingEmptyLines(brf.Sections["TO"])) == 0
+	if recipient != nil {
+		l.Recipient = *recipient
+	} else if entry, ok := addressBookRecipient(brf, addresses.AddressBook); ok {
+		l.Recipient = entry
+		replaceTo = true
+	} else {
+		l.Recipient = sectionRecipient(brf)
+	}
+
+	language := cfg.Language
+	if languageSection := parser.TrimSurroundingEmptyLines(brf.Sections["LANGUAGE"]); len(languageSection) > 0 {
+		language, err = parser.GetSingleValue(languageSection)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid language %s: %w", brf.Sections["LANGUAGE"], err)
+		}
+	}
+	l.Locale, err = locale.Get(language)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid language in %s: %w", brfFile, err)
+	}
+
+	dateValue := ""
+	if dateSection := parser.TrimSurroundingEmptyLines(brf.Sections["DATE"]); len(dateSection) > 0 {
+		dateValue, err = parser.GetSingleValue(dateSection)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid date %s: %w", brf.Sections["DATE"], err)
+		}
+	}
+	l.Date, err = ParseDate(dateValue, Now(), l.Locale)
+	if err != nil {
+		log.Printf("Using DATE section of %s as given: %v", brfFile, err)
+		l.Date = Now()
+		l.DateText = dateValue
+	}
+
+	l.Vars = variables(brf, l.Sender, l.Recipient)
+	if replaceTo {
+		l.Vars["to"] = strings.Join(l.Recipient.PostalLines(), ", ")
+	}
+	for k, v := range l.DateRenderings() {
+		l.Vars[k] = v
+	}
+	err = expandSectionVariables(l.Vars, brf, replaceTo)
+	if err != nil {
+		return nil, fmt.Errorf("Error expanding placeholders in %s: %w", brfFile, err)
+	}
+
+	l.Brf, err = parser.ExpandPlaceholders(brf, l.Vars)
+	if err != nil {
+		return nil, fmt.Errorf("Error expanding placeholders in %s: %w", brfFile, err)
+	}
+
+	if replaceTo {
+		l.Brf.Sections["TO"] = l.Recipient.PostalLines()
+	}
+	l.Brf.Sections["DATE"] = parser.BrfLines{l.DateRenderings()["date"]}
+
+	l.SignatureFile = signatureFile(l.Brf, l.Sender.Value("signature"), cfg.SenderList)
+
+	return l, nil
+}
+
+// Validate checks whether this Letter can be rendered.
+//
+// It returns an error describing all problems found. Currently it checks
+// that all enclosed files are PDF files or images and that they and the
+// signature image exist and are readable.
+func (l *Letter) Validate() error {
+	problems := l.validateSignature()
+
+	for _, e := range l.Enclosures() {
+		if !e.IsPdf() && !e.IsImage() {
+			problems = append(problems, fmt.Sprintf("Unsupported enclosure %s. Only PDF files and images are supported.", e.File))
+			continue
+		}
+		f, err := os.Open(e.File)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Enclosure %s cannot be read: %v", e.File, err))
+			continue
+		}
+		f.Close()
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Invalid letter %s:\n  %s", l.BrfFile, strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+// DateRenderings returns the date of this Letter in several formats.
+//
+// The keys of the returned map are
+//   - date: the date in the default format of the letters language (or the
+//     DateText, if the DATE section is a free text),
+//   - date.long: the date including the weekday,
+//   - date.short: the date in numeric format,
+//   - date.iso: the date in ISO format.
+func (l *Letter) DateRenderings() map[string]string {
+	renderings := map[string]string{
+		"date":       l.Locale.FormatDate(l.Date),
+		"date.long":  l.Locale.FormatLongDate(l.Date),
+		"date.short": l.Locale.FormatShortDate(l.Date),
+		"date.iso":   l.Date.Format("2006-01-02"),
+	}
+	if l.DateText != "" {
+		renderings["date"] = l.DateText
+	}
+	return renderings
+}
+
+// variables returns the variables that can be referred to by placeholders
+// inside a .brf file.
+//
+// These are the (lowercase) names of all sections except CONTENT, the
+// fields of the sender prefixed with 'from.' and the fields of the
+// recipient prefixed with 'to.'.
+// Multi-line values are joined with a comma followed by a space. The values
+// of the sections are not expanded yet (see expandSectionVariables).
+func variables(brf parser.Brf, sender, recipient address.Address) map[string]string {
+	vars := make(map[string]string)
+
+	for k, v := range brf.Sections {
+		if k == "CONTENT" {
+			continue
+		}
+		vars[strings.ToLower(k)] = strings.Join(parser.TrimSurroundingEmptyLines(v), ", ")
+	}
+
+	for k := range sender.Fields {
+		vars["from."+k] = sender.Value(k)
+	}
+
+	for k := range recipient.Fields {
+		vars["to."+k] = recipient.Value(k)
+	}
+
+	return vars
+}
+
+// expandSectionVariables expands the placeholders inside the values of the
+// variables of the sections of the given Brf (see variables), so that a
+// placeholder like ${subject} inside another section is replaced by the
+// expanded SUBJECT section.
+//
+// The variables are expanded in the order of their dependencies. An error
+// is returned if they refer to each other cyclically. Other problems (like
+// unknown variables) are left as they are, as they are reported with their
+// line when expanding the section itself. The variables date and, if
+// replaceTo is true, to are not taken from their sections and therefore not
+// expanded.
+func expandSectionVariables(vars map[string]string, brf parser.Brf, replaceTo bool) error {
+	pending := make(map[string]bool)
+	for k := range brf.Sections {
+		pending[strings.ToLower(k)] = k != "CONTENT"
+	}
+	pending["date"] = false
+	pending["to"] = pending["to"] && !replaceTo
+
+	expanding := make(map[string]bool)
+	var cycleErr error
+	var expand func(name string) (string, error)
+	expand = func(name string) (string, error) {
+		value, ok := vars[name]
+		if !ok {
+			return "${" + name + "}", nil
+		}
+		if !pending[name] {
+			return value, nil
+		}
+		if expanding[name] {
+			cycleErr = fmt.Errorf("Cyclic reference to variable ${%s}", name)
+			return "", cycleErr
+		}
+
+		expanding[name] = true
+		expanded, err := parser.ReplacePlaceholders(value, expand)
+		if cycleErr != nil {
+			return "", cycleErr
+		} else if err == nil {
+			vars[name] = expanded
+		}
+		pending[name] = false
+		return vars[name], nil
+	}
+
+	for name := range pending {
+		expand(name)
+		if cycleErr != nil {
+			return cycleErr
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package letter
+
+import (
+	"strings"
+	"testing"
+
+	"poiu.de/brief/address"
+	"poiu.de/brief/config"
+	"poiu.de/brief/parser"
+)
+
+func TestSectionPlaceholders(t *testing.T) {
+	addresses := Addresses{Senders: map[string]address.Address{
+		"me": {Fields: map[string]parser.BrfLines{"name": {"Max Mustermann"}}},
+	}}
+	cfg := &config.Config{Language: "en"}
+
+	cases := []struct {
+		content  string
+		section  string
+		expected string
+		problem  string
+	}{
+		// sections refer to the expanded content of other sections
+		{".REF\n42/${date.iso}\n.SUBJECT\nInvoice ${ref}\n.OPENING\nRe: ${subject}\n", "OPENING", "Re: Invoice 42/2020-03-03", ""},
+		{".SUBJECT\nInvoice ${ref}\n.REF\n${from.name}\n", "SUBJECT", "Invoice Max Mustermann", ""},
+		// escaped placeholders stay escaped when referred to
+		{".REF\n$${ref}\n.SUBJECT\n${ref}\n", "SUBJECT", "${ref}", ""},
+		{".SUBJECT\nInvoice ${ref}\n.REF\n${subject}\n", "", "", "Cyclic reference to variable"},
+		{".SUBJECT\nInvoice ${ref}\n.REF\n${unknown}\n", "", "", "Unknown variable ${unknown} in section REF at line 8"},
+	}
+
+	for _, c := range cases {
+		brf := parser.CreateBrf(strings.Split(".FROM\nme\n.DATE\n2020-03-03\n"+c.content, "\n"))
+		l, err := NewWithAddresses("a.brf", brf, cfg, addresses)
+		if c.problem != "" {
+			if err == nil || !strings.Contains(err.Error(), c.problem) {
+				t.Errorf("NewWithAddresses() for %q returned %v, expected error containing %q", c.content, err, c.problem)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewWithAddresses() for %q returned error %v", c.content, err)
+			continue
+		}
+		if lines := strings.Join(parser.TrimSurroundingEmptyLines(l.Brf.Sections[c.section]), "\n"); lines != c.expected {
+			t.Errorf("Section %s for %q == %q, expected %q", c.section, c.content, lines, c.expected)
+		}
+	}
+}
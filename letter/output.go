@@ -0,0 +1,127 @@
+package letter
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"poiu.de/brief/config"
+	"poiu.de/brief/parser"
+	"poiu.de/brief/utils"
+)
+
+var (
+	// PatternPlaceholder is the regex for a placeholder like {date} inside a
+	// file name pattern.
+	PatternPlaceholder = regexp.MustCompile(`\{([A-Za-z0-9_.-]+)\}`)
+)
+
+// OutputValues returns the values for the placeholders of the configured
+// OutputPattern for this Letter:
+//
+//   - name: the name of the .brf file without extension,
+//   - date: the date of the letter in ISO format,
+//   - to: the name of the recipient,
+//   - from: the name of the sender,
+//   - subject: the subject of the letter,
+//
+// and all variables of the letter (like to.city or the lowercase names of
+// the sections).
+func (l *Letter) OutputValues() map[string]string {
+	values := make(map[string]string)
+	for k, v := range l.Vars {
+		values[k] = v
+	}
+	values["name"] = strings.TrimSuffix(filepath.Base(l.BrfFile), filepath.Ext(l.BrfFile))
+	values["date"] = l.Date.Format("2006-01-02")
+	values["to"] = l.Recipient.Value("name")
+	values["from"] = l.Sender.Value("name")
+	values["subject"] = strings.Join(parser.TrimSurroundingEmptyLines(l.Brf.Sections["SUBJECT"]), " ")
+	return values
+}
+
+// OutputFile returns the path of the artifact with the given extension
+// (like "pdf") for this Letter. See OutputFile.
+func (l *Letter) OutputFile(ext string, cfg *config.Config) (string, error) {
+	return OutputFile(l.BrfFile, l.OutputValues(), ext, cfg)
+}
+
+// OutputFile returns the path of the artifact with the given extension
+// (like "pdf") for the given .brf file.
+//
+// The artifact is named like the .brf file unless an OutputPattern is
+// configured, in which case it is expanded with the given values (see
+// ExpandPattern). An extension at the end of the pattern is replaced by the
+// given one.
+//
+// The artifact is written into the directory returned by OutputDir.
+func OutputFile(brfFile string, values map[string]string, ext string, cfg *config.Config) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(brfFile), filepath.Ext(brfFile))
+	if cfg.OutputPattern != "" {
+		pattern := cfg.OutputPattern
+		if patternExt := filepath.Ext(pattern); !strings.ContainsAny(patternExt, "{}") {
+			pattern = strings.TrimSuffix(pattern, patternExt)
+		}
+		expanded, err := ExpandPattern(pattern, values)
+		if err != nil {
+			return "", fmt.Errorf("Invalid output pattern: %w", err)
+		}
+		if strings.Trim(expanded, "_.-") != "" {
+			name = expanded
+		}
+	}
+	name += "." + strings.TrimPrefix(ext, ".")
+
+	return filepath.Join(OutputDir(brfFile, cfg), name), nil
+}
+
+// ExpandPattern replaces the placeholders (like {date}) in the given file
+// name pattern by the given values, which are sanitized for file names
+// first.
+//
+// An error is returned if the pattern contains a placeholder without a
+// value.
+func ExpandPattern(pattern string, values map[string]string) (string, error) {
+	var unknown []string
+	expanded := PatternPlaceholder.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		if value, ok := values[placeholder[1:len(placeholder)-1]]; ok {
+			return utils.SanitizeFileName(value)
+		}
+		unknown = append(unknown, placeholder)
+		return placeholder
+	})
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("Unknown placeholder %s in %s", strings.Join(unknown, ", "), pattern)
+	}
+	return expanded, nil
+}
+
+// OutputDir returns the directory for the artifacts of the given .brf file.
+//
+// This is the directory of the .brf file unless an OutputDir is configured.
+// Then it is the same subdirectory of the OutputDir as the .brf file is in
+// its document root, or the OutputDir itself for .brf files outside of the
+// document roots.
+func OutputDir(brfFile string, cfg *config.Config) string {
+	dir := filepath.Dir(brfFile)
+	if cfg.OutputDir == "" {
+		return dir
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return cfg.OutputDir
+	}
+	for _, root := range cfg.DocumentRoots {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(absRoot, absDir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.Join(cfg.OutputDir, rel)
+		}
+	}
+	return cfg.OutputDir
+}
@@ -0,0 +1,83 @@
+package letter
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"poiu.de/brief/config"
+)
+
+func TestOutputFile(t *testing.T) {
+	root := filepath.Join("letters")
+	brfFile := filepath.Join(root, "2020", "invoice.brf")
+	values := map[string]string{
+		"name":    "invoice",
+		"date":    "2020-03-03",
+		"to":      "Jane Doe",
+		"subject": "Invoice 42/2020: Repairs",
+	}
+
+	cases := []struct {
+		pattern   string
+		outputDir string
+		ext       string
+		expected  string
+	}{
+		{"", "", "pdf", filepath.Join(root, "2020", "invoice.pdf")},
+		{"{date}_{to}_{subject}.pdf", "", "pdf", filepath.Join(root, "2020", "2020-03-03_Jane_Doe_Invoice_42_2020_Repairs.pdf")},
+		{"{date}_{to}_{subject}.pdf", "", "tex", filepath.Join(root, "2020", "2020-03-03_Jane_Doe_Invoice_42_2020_Repairs.tex")},
+		{"{subject}", "", "pdf", filepath.Join(root, "2020", "Invoice_42_2020_Repairs.pdf")},
+		{"", "archive", "pdf", filepath.Join("archive", "2020", "invoice.pdf")},
+	}
+
+	for _, c := range cases {
+		cfg := &config.Config{DocumentRoots: []string{root}, OutputPattern: c.pattern, OutputDir: c.outputDir}
+		file, err := OutputFile(brfFile, values, c.ext, cfg)
+		if err != nil || file != c.expected {
+			t.Errorf("OutputFile(%q, %q, %q) == %q, %v, expected %q", c.pattern, c.outputDir, c.ext, file, err, c.expected)
+		}
+	}
+
+	// empty values fall back to the name of the .brf file
+	cfg := &config.Config{OutputPattern: "{to}"}
+	if file, _ := OutputFile(brfFile, map[string]string{"to": "?"}, "pdf", cfg); file != filepath.Join(root, "2020", "invoice.pdf") {
+		t.Errorf("OutputFile() for empty values == %q, expected %q", file, filepath.Join(root, "2020", "invoice.pdf"))
+	}
+
+	// .brf files outside of the document roots are written directly into the
+	// output directory
+	cfg = &config.Config{DocumentRoots: []string{root}, OutputDir: "archive"}
+	if file, _ := OutputFile(filepath.Join("elsewhere", "a.brf"), values, "pdf", cfg); file != filepath.Join("archive", "a.pdf") {
+		t.Errorf("OutputFile() outside document roots == %q, expected %q", file, filepath.Join("archive", "a.pdf"))
+	}
+}
+
+func TestExpandPattern(t *testing.T) {
+	values := map[string]string{"date": "2020-03-03", "to": "Jane Doe", "to.city": ""}
+
+	cases := []struct {
+		pattern  string
+		expected string
+		problem  string
+	}{
+		{"{date}_{to}", "2020-03-03_Jane_Doe", ""},
+		{"letter-{to.city}", "letter-", ""},
+		{"no placeholders", "no placeholders", ""},
+		{"{date}-{unknown}", "", "Unknown placeholder {unknown}"},
+		{"{from}-{to.name}", "", "Unknown placeholder {from}, {to.name}"},
+	}
+
+	for _, c := range cases {
+		expanded, err := ExpandPattern(c.pattern, values)
+		if c.problem != "" {
+			if err == nil || !strings.Contains(err.Error(), c.problem) {
+				t.Errorf("ExpandPattern(%q) == %q, %v, expected error containing %q", c.pattern, expanded, err, c.problem)
+			}
+			continue
+		}
+		if err != nil || expanded != c.expected {
+			t.Errorf("ExpandPattern(%q) == %q, %v, expected %q", c.pattern, expanded, err, c.expected)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package letter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"poiu.de/brief/address"
+	"poiu.de/brief/config"
+	"poiu.de/brief/parser"
+)
+
+// readAddressBook reads the configured address book. If no address book is
+// configured or it doesn't exist, nil is returned.
+func readAddressBook(cfg *config.Config) (map[string]address.Address, error) {
+	if cfg.AddressBook == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(cfg.AddressBook); err != nil {
+		return nil, nil
+	}
+	addressBook, err := address.ReadFromFile(cfg.AddressBook)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading address book from %s: %w", cfg.AddressBook, err)
+	}
+	return addressBook, nil
+}
+
+// addressBookRecipient returns the entry of the given address book that is
+// named by the TO section of the given Brf.
+//
+// The TO section has to consist of a single line with the name of the
+// entry. The returned bool indicates whether such an entry was found.
+func addressBookRecipient(brf parser.Brf, addressBook map[string]address.Address) (address.Address, bool) {
+	to := parser.TrimSurroundingEmptyLines(brf.Sections["TO"])
+	if len(to) != 1 {
+		return address.Address{}, false
+	}
+	recipient, ok := addressBook[strings.TrimSpace(to[0])]
+	return recipient, ok
+}
+
+// sectionRecipient returns the recipient given by the TO section of the
+// given Brf. Its first line is the 'name' of the recipient and the
+// remaining lines its 'postal' address.
+func sectionRecipient(brf parser.Brf) address.Address {
+	to := parser.TrimSurroundingEmptyLines(brf.Sections["TO"])
+
+	recipient := address.Address{Fields: make(map[string]parser.BrfLines)}
+	if len(to) > 0 {
+		recipient.Fields["name"] = to[:1]
+	}
+	if len(to) > 1 {
+		recipient.Fields["postal"] = to[1:]
+	}
+	return recipient
+}
@@ -0,0 +1,42 @@
+package letter
+
+import (
+	"reflect"
+	"testing"
+
+	"poiu.de/brief/address"
+	"poiu.de/brief/parser"
+)
+
+func TestRecipient(t *testing.T) {
+	jane := address.Address{Fields: map[string]parser.BrfLines{"name": {"Jane Doe"}, "postal": {"Main St 1", "12345 City"}}}
+	addressBook := map[string]address.Address{"jane": jane}
+
+	cases := []struct {
+		to              parser.BrfLines
+		fromAddressBook bool
+		expected        address.Address
+	}{
+		{parser.BrfLines{"", " jane ", ""}, true, jane},
+		{parser.BrfLines{"jane", "Other St 2"}, false, address.Address{Fields: map[string]parser.BrfLines{"name": {"jane"}, "postal": {"Other St 2"}}}},
+		{parser.BrfLines{"John Doe"}, false, address.Address{Fields: map[string]parser.BrfLines{"name": {"John Doe"}}}},
+		{nil, false, address.Address{Fields: map[string]parser.BrfLines{}}},
+	}
+
+	for _, c := range cases {
+		brf := parser.Brf{Sections: map[string]parser.BrfLines{"TO": c.to}}
+		recipient, ok := addressBookRecipient(brf, addressBook)
+		if !ok {
+			recipient = sectionRecipient(brf)
+		}
+		if ok != c.fromAddressBook || !reflect.DeepEqual(recipient, c.expected) {
+			t.Errorf("Recipient for %q == %+v, %t, expected %+v, %t", c.to, recipient, ok, c.expected, c.fromAddressBook)
+		}
+	}
+
+	// without an address book the TO section is used as is
+	brf := parser.Brf{Sections: map[string]parser.BrfLines{"TO": {"jane"}}}
+	if _, ok := addressBookRecipient(brf, nil); ok {
+		t.Errorf("addressBookRecipient() without address book found a recipient")
+	}
+}
@@ -0,0 +1,76 @@
+package letter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"poiu.de/brief/parser"
+)
+
+// SignatureNone is the value of the SIGNATURE section of a .brf file to
+// omit the signature image of the sender.
+const SignatureNone = "none"
+
+// signatureFile returns the path of the signature image of the given
+// sender as specified in its 'signature' field.
+//
+// A relative path is resolved relative to the directory of the sender list
+// the sender was read from.
+// An empty string is returned if the sender has no signature image or the
+// .brf file opts out of the signature via '.SIGNATURE none'.
+func signatureFile(brf parser.Brf, senderSignature, senderList string) string {
+	if strings.TrimSpace(senderSignature) == "" {
+		return ""
+	}
+
+	signatureSection := parser.TrimSurroundingEmptyLines(brf.Sections["SIGNATURE"])
+	if len(signatureSection) == 1 && strings.TrimSpace(signatureSection[0]) == SignatureNone {
+		return ""
+	}
+
+	if filepath.IsAbs(senderSignature) {
+		return senderSignature
+	}
+	return filepath.Join(filepath.Dir(senderList), senderSignature)
+}
+
+// IsSupportedSignature returns true if the given file is of a supported
+// image type for signatures. These are PNG, JPEG, PDF and SVG files.
+func IsSupportedSignature(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".png", ".jpg", ".jpeg", ".pdf", ".svg":
+		return true
+	default:
+		return false
+	}
+}
+
+// validateSignature checks the SIGNATURE section and the signature image of
+// this Letter and returns a description of all problems found.
+func (l *Letter) validateSignature() []string {
+	problems := make([]string, 0)
+
+	signatureSection := parser.TrimSurroundingEmptyLines(l.Brf.Sections["SIGNATURE"])
+	if len(signatureSection) > 1 || (len(signatureSection) == 1 && strings.TrimSpace(signatureSection[0]) != SignatureNone) {
+		problems = append(problems, fmt.Sprintf("Invalid SIGNATURE section %q. Only %q is supported.", strings.Join(signatureSection, "\n"), SignatureNone))
+	}
+
+	if l.SignatureFile == "" {
+		return problems
+	}
+
+	if !IsSupportedSignature(l.SignatureFile) {
+		problems = append(problems, fmt.Sprintf("Unsupported signature image %s. Only PNG, JPEG, PDF and SVG files are supported.", l.SignatureFile))
+	}
+
+	f, err := os.Open(l.SignatureFile)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("Signature image %s cannot be read: %v", l.SignatureFile, err))
+	} else {
+		f.Close()
+	}
+
+	return problems
+}
@@ -0,0 +1,77 @@
+package letter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"poiu.de/brief/parser"
+)
+
+func TestSignatureFile(t *testing.T) {
+	senderList := filepath.Join("config", "brief", "senders.csv")
+	cases := []struct {
+		section   parser.BrfLines
+		signature string
+		expected  string
+	}{
+		{nil, "", ""},
+		{nil, "  ", ""},
+		{nil, "signatures/me.png", filepath.Join("config", "brief", "signatures", "me.png")},
+		{nil, "/abs/path/me.pdf", "/abs/path/me.pdf"},
+		{parser.BrfLines{"", "none", ""}, "signatures/me.png", ""},
+		{parser.BrfLines{" none "}, "/abs/path/me.pdf", ""},
+	}
+
+	for _, c := range cases {
+		brf := parser.Brf{Sections: map[string]parser.BrfLines{}}
+		if c.section != nil {
+			brf.Sections["SIGNATURE"] = c.section
+		}
+		file := signatureFile(brf, c.signature, senderList)
+		if file != c.expected {
+			t.Errorf("signatureFile(%q, %q) == %q, expected %q", c.section, c.signature, file, c.expected)
+		}
+	}
+}
+
+func TestValidateSignature(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "me.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		section   parser.BrfLines
+		signature string
+		problem   string
+	}{
+		{nil, "", ""},
+		{nil, "me.png", ""},
+		{parser.BrfLines{"none"}, "", ""},
+		{nil, "missing.png", "Signature image " + filepath.Join(dir, "missing.png") + " cannot be read"},
+		{nil, "me.gif", "Unsupported signature image " + filepath.Join(dir, "me.gif")},
+		{parser.BrfLines{"me.png"}, "", "Invalid SIGNATURE section"},
+	}
+
+	for _, c := range cases {
+		l := &Letter{BrfFile: filepath.Join(dir, "a.brf"), Brf: parser.Brf{Sections: map[string]parser.BrfLines{}}}
+		if c.section != nil {
+			l.Brf.Sections["SIGNATURE"] = c.section
+		}
+		if c.signature != "" {
+			l.SignatureFile = filepath.Join(dir, c.signature)
+		}
+		err := l.Validate()
+		if c.problem == "" {
+			if err != nil {
+				t.Errorf("Validate() for %q and %q failed: %v", c.section, c.signature, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.problem) {
+			t.Errorf("Validate() for %q and %q == %v, expected error containing %q", c.section, c.signature, err, c.problem)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+/*
+ * Package locale contains the language specific settings for letters, like
+ * the format of dates, typographic quotes and the labels used in letters.
+ */
+package locale
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Locale contains the language specific settings for a letter.
+type Locale struct {
+	// The ISO 639-1 code of the language, like "de" or "en".
+	Code string
+	// The name of the language for the LaTeX babel package.
+	BabelName string
+	// The name of the language for the LaTeX polyglossia package.
+	PolyglossiaName string
+	// The typographic opening double quote.
+	OpenQuote string
+	// The typographic closing double quote.
+	CloseQuote string
+	// The names of the months, starting with January.
+	Months [12]string
+	// The names of the weekdays, starting with Sunday.
+	Weekdays [7]string
+	// The labels used in letters, like "Anlage" or "Enclosure".
+	Labels map[string]string
+	// The format of a date with the placeholders {day}, {month}, {year},
+	// {monthName} and {weekday}. {day:02} and {month:02} are replaced by
+	// two-digit values.
+	DateFormat string
+	// The numeric format of a date with the same placeholders as
+	// DateFormat.
+	ShortDateFormat string
+	// The format of a date including the weekday with the same
+	// placeholders as DateFormat.
+	LongDateFormat string
+}
+
+var (
+	locales = map[string]*Locale{
+		"de": {
+			Code:            "de",
+			BabelName:       "ngerman",
+			PolyglossiaName: "german",
+			OpenQuote:       "„",
+			CloseQuote:      "“",
+			Months:          [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+			Weekdays:        [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+			Labels: map[string]string{
+				"date":       "Datum",
+				"email":      "E-Mail",
+				"enclosure":  "Anlage",
+				"enclosures": "Anlagen",
+				"page":       "Seite",
+				"phone":      "Telefon",
+				"ref":        "Unser Zeichen",
+				"subject":    "Betreff",
+			},
+			DateFormat:      "{day}. {monthName} {year}",
+			ShortDateFormat: "{day:02}.{month:02}.{year}",
+			LongDateFormat:  "{weekday}, {day}. {monthName} {year}",
+		},
+		"en": {
+			Code:            "en",
+			BabelName:       "english",
+			PolyglossiaName: "english",
+			OpenQuote:       "“",
+			CloseQuote:      "”",
+			Months:          [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+			Weekdays:        [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+			Labels: map[string]string{
+				"date":       "Date",
+				"email":      "Email",
+				"enclosure":  "Enclosure",
+				"enclosures": "Enclosures",
+				"page":       "Page",
+				"phone":      "Phone",
+				"ref":        "Our reference",
+				"subject":    "Subject",
+			},
+			DateFormat:      "{monthName} {day}, {year}",
+			ShortDateFormat: "{month:02}/{day:02}/{year}",
+			LongDateFormat:  "{weekday}, {monthName} {day}, {year}",
+		},
+	}
+)
+
+// Get returns the Locale for the given language code.
+//
+// The language code may contain a region or encoding like "de_DE.UTF-8" or
+// "en-GB". Those are ignored and only the language itself is considered.
+//
+// If no Locale is available for the given language, an error is returned.
+func Get(code string) (*Locale, error) {
+	lang := strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+
+	l, ok := locales[lang]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported language %s. Supported languages are: %s", code, strings.Join(Supported(), ", "))
+	}
+	return l, nil
+}
+
+// Supported returns the codes of all supported languages.
+func Supported() []string {
+	codes := make([]string, 0, len(locales))
+	for k := range locales {
+		codes = append(codes, k)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Label returns the label with the given key, like "enclosure".
+//
+// If the key is unknown, the key itself is returned.
+func (l *Locale) Label(key string) string {
+	if label, ok := l.Labels[key]; ok {
+		return label
+	}
+	return key
+}
+
+// FormatDate formats the given date according to the DateFormat of this
+// Locale, like "3. März 2020" or "March 3, 2020".
+func (l *Locale) FormatDate(t time.Time) string {
+	return l.format(l.DateFormat, t)
+}
+
+// FormatShortDate formats the given date according to the ShortDateFormat
+// of this Locale, like "03.03.2020" or "03/03/2020".
+func (l *Locale) FormatShortDate(t time.Time) string {
+	return l.format(l.ShortDateFormat, t)
+}
+
+// FormatLongDate formats the given date according to the LongDateFormat
+// of this Locale, like "Dienstag, 3. März 2020" or "Tuesday, March 3,
+// 2020".
+func (l *Locale) FormatLongDate(t time.Time) string {
+	return l.format(l.LongDateFormat, t)
+}
+
+// format replaces the placeholders in the given format with the
+// corresponding values of the given date.
+func (l *Locale) format(format string, t time.Time) string {
+	r := strings.NewReplacer(
+		"{day}", fmt.Sprint(t.Day()),
+		"{day:02}", fmt.Sprintf("%02d", t.Day()),
+		"{month}", fmt.Sprint(int(t.Month())),
+		"{month:02}", fmt.Sprintf("%02d", int(t.Month())),
+		"{monthName}", l.Months[t.Month()-1],
+		"{year}", fmt.Sprint(t.Year()),
+		"{weekday}", l.Weekdays[t.Weekday()],
+	)
+	return r.Replace(format)
+}
+
+// Quote replaces straight double quotes (") in the given text with the
+// typographic quotes of this Locale.
+//
+// A quote is considered an opening quote if it is at the start of the text
+// or follows whitespace or an opening bracket. All other quotes are
+// considered closing quotes.
+func (l *Locale) Quote(text string) string {
+	return l.quote(text, false)
+}
+
+// QuoteTex replaces straight double quotes like Quote, but for text that is
+// passed to LaTeX.
+//
+// For German, quotes that are part of a babel shorthand (like "a in M"adchen
+// or "- and "` outside of quotations) are left alone, so that LaTeX letters
+// using them keep working. Shorthands at the start of a word can't be told
+// apart from quotations and are replaced.
+func (l *Locale) QuoteTex(text string) string {
+	return l.quote(text, l.Code == "de")
+}
+
+// quote replaces straight double quotes as described in Quote and QuoteTex.
+// If shorthands is true, babel shorthands are left alone.
+func (l *Locale) quote(text string, shorthands bool) string {
+	if !strings.ContainsRune(text, '"') {
+		return text
+	}
+
+	runes := []rune(text)
+	var b strings.Builder
+	b.Grow(len(text))
+	var prev rune = ' '
+	open := false
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		if c != '"' {
+			b.WriteRune(c)
+			prev = c
+			continue
+		}
+
+		if shorthands && i+1 < len(runes) && isBabelShorthand(prev, runes[i+1], open) {
+			// keep the whole shorthand, so that the second quote of "" is
+			// not replaced either
+			b.WriteRune(c)
+			b.WriteRune(runes[i+1])
+			prev = runes[i+1]
+			i++
+			continue
+		}
+
+		switch prev {
+		case ' ', '\t', '\n', '(', '[', '{':
+			b.WriteString(l.OpenQuote)
+			open = true
+		default:
+			b.WriteString(l.CloseQuote)
+			open = false
+		}
+		prev = c
+	}
+	return b.String()
+}
+
+// isBabelShorthand returns true if a double quote between the given runes
+// is part of a shorthand of the german babel package, like "a or "-.
+//
+// A quote between two letters is always considered a shorthand. A quote
+// followed by one of the symbols used in shorthands is only considered one
+// if no quotation is open, since it would close it otherwise.
+func isBabelShorthand(prev, next rune, open bool) bool {
+	if unicode.IsLetter(prev) && unicode.IsLetter(next) {
+		return true
+	}
+	return !open && strings.ContainsRune("-=|~`'<>\"", next)
+}
@@ -0,0 +1,87 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	cases := []struct {
+		input string
+		code  string
+	}{
+		{"de", "de"},
+		{"de_DE.UTF-8", "de"},
+		{"en-GB", "en"},
+		{" EN ", "en"},
+	}
+	for _, tt := range cases {
+		l, err := Get(tt.input)
+		if err != nil {
+			t.Errorf("Get(%q) returned error %v", tt.input, err)
+		} else if l.Code != tt.code {
+			t.Errorf("Get(%q) == %q, expected %q", tt.input, l.Code, tt.code)
+		}
+	}
+
+	if _, err := Get("tlh"); err == nil {
+		t.Errorf("Get(%q) should return an error", "tlh")
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	date := time.Date(2020, time.March, 3, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		code     string
+		date     string
+		short    string
+		longDate string
+	}{
+		{"de", "3. März 2020", "03.03.2020", "Dienstag, 3. März 2020"},
+		{"en", "March 3, 2020", "03/03/2020", "Tuesday, March 3, 2020"},
+	}
+	for _, tt := range cases {
+		l, _ := Get(tt.code)
+		if s := l.FormatDate(date); s != tt.date {
+			t.Errorf("FormatDate() for %s == %q, expected %q", tt.code, s, tt.date)
+		}
+		if s := l.FormatLongDate(date); s != tt.longDate {
+			t.Errorf("FormatLongDate() for %s == %q, expected %q", tt.code, s, tt.longDate)
+		}
+		if s := l.FormatShortDate(date); s != tt.short {
+			t.Errorf("FormatShortDate() for %s == %q, expected %q", tt.code, s, tt.short)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	de, _ := Get("de")
+	en, _ := Get("en")
+
+	cases := []struct {
+		loc       *Locale
+		input     string
+		output    string
+		texOutput string
+	}{
+		{de, `Er sagte "Hallo".`, `Er sagte „Hallo“.`, `Er sagte „Hallo“.`},
+		{de, `"Hallo" ("Welt")`, `„Hallo“ („Welt“)`, `„Hallo“ („Welt“)`},
+		{de, "no quotes", "no quotes", "no quotes"},
+		// babel shorthands are only kept for German LaTeX
+		{de, `M"adchen und Stra"se`, `M“adchen und Stra“se`, `M"adchen und Stra"se`},
+		{de, `Donau"-dampf"=schiff ""gut`, `Donau“-dampf“=schiff „“gut`, `Donau"-dampf"=schiff ""gut`},
+		{de, `"` + "`" + `Hallo"' sagte sie`, `„` + "`" + `Hallo“' sagte sie`, `"` + "`" + `Hallo"' sagte sie`},
+		{de, `"Hallo"-Rufe und "Gr"u"se"`, `„Hallo“-Rufe und „Gr“u“se“`, `„Hallo“-Rufe und „Gr"u"se“`},
+		{en, `He said "hello".`, `He said “hello”.`, `He said “hello”.`},
+		{en, `the "a"b" case`, `the “a”b” case`, `the “a”b” case`},
+	}
+	for _, tt := range cases {
+		if s := tt.loc.Quote(tt.input); s != tt.output {
+			t.Errorf("Quote(%q) for %s == %q, expected %q", tt.input, tt.loc.Code, s, tt.output)
+		}
+		if s := tt.loc.QuoteTex(tt.input); s != tt.texOutput {
+			t.Errorf("QuoteTex(%q) for %s == %q, expected %q", tt.input, tt.loc.Code, s, tt.texOutput)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSmtpServer is a minimal SMTP server that accepts a single message.
+type fakeSmtpServer struct {
+	listener net.Listener
+	// Whether to advertise STARTTLS (without actually supporting it).
+	startTLS bool
+	// The received envelope and data.
+	from string
+	to   []string
+	data string
+	done chan struct{}
+}
+
+func newFakeSmtpServer(t *testing.T, startTLS bool) *fakeSmtpServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot start fake SMTP server: %v", err)
+	}
+
+	s := &fakeSmtpServer{listener: l, startTLS: startTLS, done: make(chan struct{})}
+	go s.serve()
+	return s
+}
+
+func (s *fakeSmtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSmtpServer) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP fake")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			if s.startTLS {
+				reply("250-localhost")
+				reply("250 STARTTLS")
+			} else {
+				reply("250 localhost")
+			}
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.from = strings.Trim(strings.TrimSpace(line)[10:], "<>")
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.to = append(s.to, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.data = data.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func testMessage() Message {
+	return Message{
+		From:    mail.Address{Name: "Marco Herrn", Address: "me@example.org"},
+		To:      []mail.Address{{Name: "Finanzamt Berlin", Address: "fa@example.org"}},
+		Subject: "Steuererklärung 2024",
+		Body:    "Sehr geehrte Damen und Herren,\nanbei die Unterlagen.\n",
+		Attachments: []Attachment{
+			{Name: "brief.pdf", ContentType: "application/pdf", Data: bytes.Repeat([]byte("%PDF"), 100)},
+		},
+	}
+}
+
+func TestMessageBytes(t *testing.T) {
+	m := testMessage()
+	data, err := m.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() failed: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Cannot parse message: %v", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != m.Subject {
+		t.Errorf("Subject == %q, expected %q", subject, m.Subject)
+	}
+	to, err := msg.Header.AddressList("To")
+	if err != nil || len(to) != 1 || to[0].Address != "fa@example.org" {
+		t.Errorf("To == %v, expected fa@example.org", to)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type == %q, expected multipart/mixed", mediaType)
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	body, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("Cannot read body part: %v", err)
+	}
+	content, _ := ioutil.ReadAll(body)
+	if string(content) != strings.ReplaceAll(m.Body, "\n", "\r\n") {
+		t.Errorf("Body == %q, expected %q", content, m.Body)
+	}
+
+	attachment, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("Cannot read attachment part: %v", err)
+	}
+	if attachment.FileName() != "brief.pdf" {
+		t.Errorf("Attachment name == %q, expected brief.pdf", attachment.FileName())
+	}
+	if attachment.Header.Get("Content-Transfer-Encoding") != "base64" {
+		t.Errorf("Attachment encoding == %q, expected base64", attachment.Header.Get("Content-Transfer-Encoding"))
+	}
+}
+
+func TestSend(t *testing.T) {
+	s := newFakeSmtpServer(t, false)
+	defer s.listener.Close()
+
+	err := Send(SmtpConfig{Host: "127.0.0.1", Port: s.port()}, testMessage())
+	if err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	<-s.done
+
+	if s.from != "me@example.org" {
+		t.Errorf("MAIL FROM == %q, expected me@example.org", s.from)
+	}
+	if len(s.to) != 1 || s.to[0] != "fa@example.org" {
+		t.Errorf("RCPT TO == %q, expected [fa@example.org]", s.to)
+	}
+	if !strings.Contains(s.data, "filename=brief.pdf") {
+		t.Errorf("Sent data does not contain the attachment:\n%s", s.data)
+	}
+}
+
+func TestSendRequiresStartTLS(t *testing.T) {
+	s := newFakeSmtpServer(t, false)
+	defer s.listener.Close()
+
+	err := Send(SmtpConfig{Host: "127.0.0.1", Port: s.port(), StartTLS: true}, testMessage())
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("Send() == %v, expected STARTTLS error", err)
+	}
+}
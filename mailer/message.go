@@ -0,0 +1,160 @@
+/*
+ * Package mailer composes MIME email messages and sends them via SMTP.
+ */
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Attachment is a file attached to a Message.
+type Attachment struct {
+	// The file name shown to the recipient.
+	Name string
+	// The MIME type of the content, like "application/pdf".
+	ContentType string
+	// The content of the file.
+	Data []byte
+}
+
+// Message is an email with a plain text body and optional attachments.
+type Message struct {
+	From        mail.Address
+	To          []mail.Address
+	Subject     string
+	Body        string
+	Attachments []Attachment
+	// The date of the message. If zero, the current time is used.
+	Date time.Time
+}
+
+// Recipients returns the plain email addresses of all recipients of this
+// Message.
+func (m Message) Recipients() []string {
+	r := make([]string, len(m.To))
+	for i, to := range m.To {
+		r[i] = to.Address
+	}
+	return r
+}
+
+// Bytes returns this Message in MIME format (as defined in RFC 5322 and RFC
+// 2045), suitable to be sent via SMTP or written to an .eml file.
+//
+// The body is encoded as quoted-printable and the attachments as base64.
+func (m Message) Bytes() ([]byte, error) {
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	to := make([]string, len(m.To))
+	for i := range m.To {
+		to[i] = m.To[i].String()
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.From.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", messageId(m.From.Address))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+
+	if len(m.Attachments) == 0 {
+		fmt.Fprintf(&buf, "Content-Type: text/plain; charset=utf-8\r\n")
+		fmt.Fprintf(&buf, "Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		err := writeQuotedPrintable(&buf, m.Body)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	w := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", w.Boundary())
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = writeQuotedPrintable(part, m.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range m.Attachments {
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.ContentType, map[string]string{"name": a.Name})},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {disposition},
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = writeBase64(part, a.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeQuotedPrintable writes the given text with CRLF line endings and
+// quoted-printable encoded to the given writer.
+func writeQuotedPrintable(w io.Writer, text string) error {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+	qp := quotedprintable.NewWriter(w)
+	_, err := qp.Write([]byte(text))
+	if err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64 writes the given data base64 encoded in lines of 76
+// characters to the given writer.
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := w.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := w.Write([]byte(encoded + "\r\n"))
+	return err
+}
+
+// messageId returns a new unique Message-ID for the given sender address.
+func messageId(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+
+	b := make([]byte, 12)
+	rand.Read(b)
+	return fmt.Sprintf("<%x.%d@%s>", b, time.Now().Unix(), domain)
+}
@@ -0,0 +1,92 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+// SmtpConfig contains the settings for connecting to an SMTP server.
+type SmtpConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// Whether to require STARTTLS. If false, STARTTLS is still used if the
+	// server supports it.
+	StartTLS bool
+	// Whether to skip the verification of the servers TLS certificate.
+	// Only intended for testing.
+	InsecureSkipVerify bool
+}
+
+// Send sends the given Message via the SMTP server given in cfg.
+//
+// If the server supports STARTTLS, the connection is encrypted before
+// authenticating. If cfg.StartTLS is set and the server doesn't support
+// STARTTLS, an error is returned. Authentication is only done if a
+// username is configured.
+func Send(cfg SmtpConfig, m Message) error {
+	if cfg.Host == "" {
+		return fmt.Errorf("No SMTP host configured")
+	}
+	if len(m.To) == 0 {
+		return fmt.Errorf("No recipient given")
+	}
+
+	data, err := m.Bytes()
+	if err != nil {
+		return fmt.Errorf("Error composing message: %w", err)
+	}
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	c, err := smtp.Dial(addr)
+	if err != nil {
+		return fmt.Errorf("Error connecting to SMTP server %s: %w", addr, err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: cfg.Host, InsecureSkipVerify: cfg.InsecureSkipVerify})
+		if err != nil {
+			return fmt.Errorf("Error starting TLS with SMTP server %s: %w", addr, err)
+		}
+	} else if cfg.StartTLS {
+		return fmt.Errorf("SMTP server %s does not support STARTTLS", addr)
+	}
+
+	if cfg.Username != "" {
+		err = c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host))
+		if err != nil {
+			return fmt.Errorf("Error authenticating at SMTP server %s: %w", addr, err)
+		}
+	}
+
+	err = c.Mail(m.From.Address)
+	if err != nil {
+		return fmt.Errorf("SMTP server %s rejected sender %s: %w", addr, m.From.Address, err)
+	}
+	for _, to := range m.Recipients() {
+		err = c.Rcpt(to)
+		if err != nil {
+			return fmt.Errorf("SMTP server %s rejected recipient %s: %w", addr, to, err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("Error sending message to SMTP server %s: %w", addr, err)
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		return fmt.Errorf("Error sending message to SMTP server %s: %w", addr, err)
+	}
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("SMTP server %s rejected message: %w", addr, err)
+	}
+
+	return c.Quit()
+}
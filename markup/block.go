@@ -0,0 +1,81 @@
+package markup
+
+import (
+	"regexp"
+)
+
+var (
+	// Regex for a line indicating the markup language for a whole section
+	patternMarkupType *regexp.Regexp = regexp.MustCompile(`^\.([a-z]+)\s*$`)
+	// Regex for a line indicating the start of a markup block
+	patternMarkupTypeBlockStart *regexp.Regexp = regexp.MustCompile(`^\.([a-z]+)-{2,}\s*$`)
+	// Regex for a line indicating the end of a markup block
+	patternMarkupTypeBlockEnd *regexp.Regexp = regexp.MustCompile(`^-{2,}\s*$`)
+)
+
+// Block is a part of a section in a .brf file that is either plain text
+// or written in a specific markup language.
+type Block struct {
+	// The markup type of this Block. Empty for plain text.
+	MarkupType string
+	// The lines of this Block without the lines indicating the markup type.
+	Lines []string
+	// The lines of this Block as they appear in the .brf file, including the
+	// lines indicating the markup type.
+	Raw []string
+}
+
+// SplitBlocks splits the given lines of a section into plain text and
+// markup blocks.
+//
+// If the first line specifies a markup type (like ".markdown"), the whole
+// section is returned as a single Block of that markup type.
+// Otherwise markup blocks are enclosed by a line specifying the markup
+// type (like ".markdown--") and a line consisting only of dashes (like
+// "--"). All lines outside of such markup blocks are returned as plain text
+// Blocks.
+// A markup block without end is treated as plain text.
+func SplitBlocks(lines []string) []Block {
+	blocks := make([]Block, 0)
+	if len(lines) == 0 {
+		return blocks
+	}
+
+	sectionMarkupType := patternMarkupType.FindStringSubmatch(lines[0])
+	if sectionMarkupType != nil {
+		return append(blocks, Block{MarkupType: sectionMarkupType[1], Lines: lines[1:], Raw: lines})
+	}
+
+	plainStart := 0
+	for i := 0; i < len(lines); i++ {
+		markupBlockType := patternMarkupTypeBlockStart.FindStringSubmatch(lines[i])
+		if markupBlockType == nil {
+			continue
+		}
+
+		blockEnd := -1
+		for x := i + 1; x < len(lines); x++ {
+			if patternMarkupTypeBlockEnd.MatchString(lines[x]) {
+				blockEnd = x
+				break
+			}
+		}
+		if blockEnd == -1 {
+			// no markup block end; the remainder is plain text
+			break
+		}
+
+		if plainStart < i {
+			blocks = append(blocks, Block{Lines: lines[plainStart:i], Raw: lines[plainStart:i]})
+		}
+		blocks = append(blocks, Block{MarkupType: markupBlockType[1], Lines: lines[i+1 : blockEnd], Raw: lines[i : blockEnd+1]})
+		i = blockEnd
+		plainStart = blockEnd + 1
+	}
+
+	if plainStart < len(lines) {
+		blocks = append(blocks, Block{Lines: lines[plainStart:], Raw: lines[plainStart:]})
+	}
+
+	return blocks
+}
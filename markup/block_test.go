@@ -0,0 +1,35 @@
+package markup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitBlocks(t *testing.T) {
+	cases := []struct {
+		input  []string
+		blocks []Block
+	}{
+		{[]string{}, []Block{}},
+		{[]string{"plain", "text"}, []Block{
+			{Lines: []string{"plain", "text"}, Raw: []string{"plain", "text"}},
+		}},
+		{[]string{".markdown", "*all*", "markdown"}, []Block{
+			{MarkupType: "markdown", Lines: []string{"*all*", "markdown"}, Raw: []string{".markdown", "*all*", "markdown"}},
+		}},
+		{[]string{"before", ".markdown--", "*a*", "*b*", "--", "after"}, []Block{
+			{Lines: []string{"before"}, Raw: []string{"before"}},
+			{MarkupType: "markdown", Lines: []string{"*a*", "*b*"}, Raw: []string{".markdown--", "*a*", "*b*", "--"}},
+			{Lines: []string{"after"}, Raw: []string{"after"}},
+		}},
+		{[]string{"before", ".markdown--", "*a*"}, []Block{
+			{Lines: []string{"before", ".markdown--", "*a*"}, Raw: []string{"before", ".markdown--", "*a*"}},
+		}},
+	}
+	for _, tt := range cases {
+		blocks := SplitBlocks(tt.input)
+		if !reflect.DeepEqual(blocks, tt.blocks) {
+			t.Errorf("SplitBlocks(%q) == %+v, expected %+v", tt.input, blocks, tt.blocks)
+		}
+	}
+}
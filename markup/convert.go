@@ -1,64 +1,138 @@
 package markup
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"poiu.de/brief/cmdline"
 	"poiu.de/brief/config"
+	"poiu.de/brief/utils"
+)
+
+// The default maximum line width of converted plain text (see %w).
+const DefaultWidth = 72
+
+// Target formats for the conversion of markup
+const (
+	TargetLatex = "latex"
+	TargetHtml  = "html"
+	TargetPlain = "plain"
+	TargetTypst = "typst"
 )
 
 // Converter is converts content written in a specific markup language into
-// LaTeX code.
+// LaTeX, HTML, Typst or plain text.
 type Converter struct {
 	// The markup type that this Converter handles.
 	markupType string
+	// The target format of the conversion (TargetLatex, TargetHtml,
+	// TargetPlain or TargetTypst).
+	target string
 	// The command to use to convert markup text to LaTeX.
 	converterCmd string
+	// The placeholders to substitute in the converterCmd.
+	placeholders cmdline.Placeholders
+	// The configuration to take the timeout of the converterCmd from.
+	cfg *config.Config
 }
 
 type converter interface {
-	// Convert converts the given lines of markup text into a string of latex code.
+	// Convert converts the given lines of markup text into a string of latex,
+	// html or typst code or plain text.
 	//
-	// If conversion fails for some reason, an empty string and an error is
-	// returned.
-	Convert(lines []string) (string, error)
+	// If conversion fails for some reason or the given context is cancelled,
+	// an empty string and an error is returned.
+	Convert(ctx context.Context, lines []string) (string, error)
 }
 
 // NewConverter returns a new Converter for the given markup type.
 // If no converter can be found for the given markup type, an error will be
 // returned.
-func NewConverter(markupType string, cfg *config.Config) (*Converter, error) {
-	if cfg.MarkupConverters[markupType] != "" {
-		return &Converter{markupType: markupType, converterCmd: cfg.MarkupConverters[markupType]}, nil
-	} else if cfg.MarkupConverters["*"] != "" {
-		return &Converter{markupType: markupType, converterCmd: strings.ReplaceAll(cfg.MarkupConverters["*"], "%m", markupType)}, nil
-	} else {
+//
+// The configured converter commands may contain the placeholders %m for the
+// markup type, %l for the language (like "de") of the converted text, %t
+// for the target format (like "latex" or "html") and %w for the maximum
+// line width of the converted text (see SetWidth).
+// Converter commands without the placeholder %t are expected to produce
+// LaTeX code. Therefore an error is returned for them if the target is not
+// LaTeX.
+//
+// Converter commands read the markup text from stdin, unless they contain
+// the placeholder %f. In that case the markup text is written to a
+// temporary file that can be referred to via the placeholders of
+// cmdline.FilePlaceholders (%o being the directory of the temporary file).
+func NewConverter(markupType string, language string, target string, cfg *config.Config) (*Converter, error) {
+	converterCmd := cfg.MarkupConverters[markupType]
+	if converterCmd == "" {
+		converterCmd = cfg.MarkupConverters["*"]
+	}
+	if converterCmd == "" {
 		return nil, fmt.Errorf("No converter configured for markupType %s. Consider installing pandoc.", markupType)
 	}
-	if markupType == "markdown" {
-		return &Converter{markupType: markupType}, nil
-	} else {
-		return nil, fmt.Errorf("No converter configured for markupType %s", markupType)
+	if target != TargetLatex && !cmdline.HasPlaceholder(converterCmd, 't') {
+		return nil, fmt.Errorf("Converter for markupType %s does not support target %s. It lacks the placeholder %%t.", markupType, target)
 	}
+
+	placeholders := cmdline.Placeholders{'m': markupType, 'l': language, 't': target, 'w': strconv.Itoa(DefaultWidth)}
+	return &Converter{markupType: markupType, target: target, converterCmd: converterCmd, placeholders: placeholders, cfg: cfg}, nil
 }
 
-// Convert converts the given lines of markup text into a string of latex code.
+// SetWidth sets the maximum line width of the converted text, which is
+// passed to the converter command via the placeholder %w. It defaults to
+// DefaultWidth.
+func (c *Converter) SetWidth(width int) {
+	c.placeholders['w'] = strconv.Itoa(width)
+}
+
+// Convert converts the given lines of markup text into a string of code in
+// the target format of this Converter.
+//
+// The conversion is aborted if the given context is cancelled or the
+// configured timeout for markup converters has passed.
 //
 // If conversion fails for some reason, an empty string and an error is
 // returned.
-func (c *Converter) Convert(lines []string) (string, error) {
+func (c *Converter) Convert(ctx context.Context, lines []string) (string, error) {
 	if len(strings.TrimSpace(c.converterCmd)) == 0 {
 		return "", fmt.Errorf("No converterCmd defined for markup %s", c.markupType)
 	}
 
-	cmdLine := c.converterCmd
-	stdin := strings.NewReader(strings.Join(lines, "\n"))
+	ctx, cancel := c.cfg.CommandContext(ctx, config.TimeoutMarkup)
+	defer cancel()
+
+	input := strings.Join(lines, "\n")
+	placeholders := c.placeholders
+	var stdin io.Reader = strings.NewReader(input)
+	if cmdline.HasPlaceholder(c.converterCmd, 'f') {
+		tmpDir, err := os.MkdirTemp("", "brief-markup-")
+		if err != nil {
+			return "", fmt.Errorf("Cannot create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tmpDir)
+
+		inputFile := filepath.Join(tmpDir, "input."+utils.SanitizeFileName(c.markupType))
+		err = os.WriteFile(inputFile, []byte(input), 0644)
+		if err != nil {
+			return "", fmt.Errorf("Cannot write %s markup to temporary file: %w", c.markupType, err)
+		}
+
+		placeholders = cmdline.FilePlaceholders(inputFile, tmpDir)
+		for k, v := range c.placeholders {
+			placeholders[k] = v
+		}
+		stdin = nil
+	}
+
 	stdout := &strings.Builder{}
 	stderr := &strings.Builder{}
-	err := cmdline.Execute(cmdLine, "", stdin, stdout, stderr)
+	err := cmdline.ExecuteWithPlaceholders(ctx, c.converterCmd, placeholders, "", stdin, stdout, stderr)
 	if err != nil {
-		return "", fmt.Errorf("Error converting %s markup via %s: %w", c.markupType, c.converterCmd, err)
+		return "", fmt.Errorf("Error converting %s markup: %w", c.markupType, err)
 	}
 
 	return stdout.String(), nil
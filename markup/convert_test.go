@@ -0,0 +1,93 @@
+package markup
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"poiu.de/brief/cmdline"
+	"poiu.de/brief/config"
+)
+
+func TestNewConverter(t *testing.T) {
+	cfg := &config.Config{MarkupConverters: map[string]string{
+		"*":        "pandoc -f %m -t %t -M lang=%l",
+		"asciidoc": "asciidoctor -b docbook5 - | pandoc -f docbook -t latex",
+	}}
+
+	cases := []struct {
+		markupType string
+		target     string
+		expected   string
+		err        bool
+	}{
+		{"markdown", TargetLatex, "pandoc -f %m -t %t -M lang=%l", false},
+		{"markdown", TargetHtml, "pandoc -f %m -t %t -M lang=%l", false},
+		{"asciidoc", TargetLatex, "asciidoctor -b docbook5 - | pandoc -f docbook -t latex", false},
+		{"asciidoc", TargetHtml, "", true},
+	}
+
+	for _, c := range cases {
+		mc, err := NewConverter(c.markupType, "de", c.target, cfg)
+		if c.err {
+			if err == nil {
+				t.Errorf("NewConverter(%q, %q) succeeded, expected error", c.markupType, c.target)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewConverter(%q, %q) failed: %v", c.markupType, c.target, err)
+			continue
+		}
+		if mc.converterCmd != c.expected {
+			t.Errorf("NewConverter(%q, %q).converterCmd == %q, expected %q", c.markupType, c.target, mc.converterCmd, c.expected)
+		}
+		placeholders := cmdline.Placeholders{'m': c.markupType, 'l': "de", 't': c.target, 'w': "72"}
+		if !reflect.DeepEqual(mc.placeholders, placeholders) {
+			t.Errorf("NewConverter(%q, %q).placeholders == %q, expected %q", c.markupType, c.target, mc.placeholders, placeholders)
+		}
+	}
+
+	_, err := NewConverter("markdown", "de", TargetLatex, &config.Config{})
+	if err == nil {
+		t.Errorf("NewConverter without converters succeeded, expected error")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	cfg := &config.Config{MarkupConverters: map[string]string{
+		"*":     "sed s/^/%m-%t-%l:/",
+		"file":  "cat %f",
+		"quote": "echo \"%m %%t\" '%m'",
+		"width": "echo %w",
+	}}
+
+	cases := []struct {
+		markupType string
+		expected   string
+	}{
+		// the markup type comes from the letter and must not be interpreted
+		{"mark down;$x", "mark down;$x-latex-de:line 1\nmark down;$x-latex-de:line 2\n"},
+		{"file", "line 1\nline 2"},
+		{"quote", "quote %t %m\n"},
+		{"width", "40\n"},
+	}
+
+	for _, c := range cases {
+		mc, err := NewConverter(c.markupType, "de", TargetLatex, cfg)
+		if err != nil {
+			t.Errorf("NewConverter(%q) failed: %v", c.markupType, err)
+			continue
+		}
+		mc.SetWidth(40)
+		s, err := mc.Convert(context.Background(), []string{"line 1", "line 2"})
+		if err != nil {
+			t.Errorf("Convert() for %q failed: %v", c.markupType, err)
+			continue
+		}
+		if strings.TrimSuffix(s, "\n") != strings.TrimSuffix(c.expected, "\n") {
+			t.Errorf("Convert() for %q == %q, expected %q", c.markupType, s, c.expected)
+		}
+	}
+}
@@ -2,9 +2,11 @@ package parser
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"regexp"
+	"strings"
 )
 
 var (
@@ -63,6 +65,19 @@ func ReadBrfFile(brfFilePath string) (Brf, error) {
 	return brf, nil
 }
 
+// SectionId returns the name of the section started by the given line, like
+// "CONTENT" for the line ".CONTENT".
+//
+// If the given line does not start a new section, an empty string is
+// returned.
+func SectionId(line string) string {
+	sectionIdMatch := patternSectionId.FindStringSubmatch(line)
+	if sectionIdMatch == nil {
+		return ""
+	}
+	return sectionIdMatch[1]
+}
+
 // CreateBrf creates a Brf object from the given lines of a .brf file.
 func CreateBrf(brfLines BrfLines) Brf {
 	brf := NewBrf(brfLines)
@@ -91,3 +106,40 @@ func CreateBrf(brfLines BrfLines) Brf {
 
 	return brf
 }
+
+// TrimSurroundingEmptyLines returns a slice of the given BrfLines with
+// leading and trailing empty lines removed.
+// Empty lines in between remain as is.
+func TrimSurroundingEmptyLines(brfLines BrfLines) BrfLines {
+	firstNonEmpty := -1
+	lastNonEmpty := -1
+	for idx, line := range brfLines {
+		if strings.TrimSpace(line) != "" {
+			lastNonEmpty = idx
+			if firstNonEmpty == -1 {
+				firstNonEmpty = idx
+			}
+		}
+	}
+
+	if firstNonEmpty == -1 {
+		return BrfLines{}
+	}
+
+	return brfLines[firstNonEmpty : lastNonEmpty+1]
+}
+
+// GetSingleValue returns a string with the content of the only non-empty
+// line in the given BrfLines.
+// If no or more than one non-empty line is contained in the given BrfLines
+// an error will be returned.
+func GetSingleValue(brfLines BrfLines) (string, error) {
+	brfLines = TrimSurroundingEmptyLines(brfLines)
+	if len(brfLines) == 0 {
+		return "", errors.New("No line with content found")
+	} else if len(brfLines) > 1 {
+		return "", errors.New("More than one line with content found")
+	} else {
+		return strings.TrimSpace(brfLines[0]), nil
+	}
+}
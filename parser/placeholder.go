@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Regex for a placeholder like ${to.name} inside a line of a .brf file.
+	// A placeholder prefixed with an additional dollar sign ($${...}) is
+	// escaped and will not be expanded.
+	patternPlaceholder *regexp.Regexp = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+	// Regex for a valid variable name inside a placeholder
+	patternVariableName *regexp.Regexp = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+)
+
+// UnknownVariableError is returned by ExpandPlaceholders if a placeholder
+// refers to a variable that is not defined.
+type UnknownVariableError struct {
+	// The name of the unknown variable.
+	Variable string
+	// The section in which the placeholder was found.
+	Section string
+	// The line number (starting at 1) in the .brf file in which the
+	// placeholder was found.
+	Line int
+}
+
+func (e *UnknownVariableError) Error() string {
+	return fmt.Sprintf("Unknown variable ${%s} in section %s at line %d", e.Variable, e.Section, e.Line)
+}
+
+// ExpandPlaceholders returns a new Brf object where all placeholders like
+// ${date} or ${to.name} inside the sections of the given Brf are replaced
+// by the corresponding value in vars.
+//
+// Section identifiers and lines outside of any section are left as is.
+// To write a literal ${...} into a .brf file prefix it with another dollar
+// sign: $${...}.
+//
+// If a placeholder refers to a variable that is not contained in vars an
+// UnknownVariableError is returned. If a placeholder contains an invalid
+// variable name an error is returned as well.
+func ExpandPlaceholders(brf Brf, vars map[string]string) (Brf, error) {
+	expandedLines := make(BrfLines, len(brf.Lines))
+
+	var currentSection string
+	for idx, line := range brf.Lines {
+		sectionIdMatch := patternSectionId.FindStringSubmatch(line)
+		if sectionIdMatch != nil {
+			currentSection = sectionIdMatch[1]
+			expandedLines[idx] = line
+			continue
+		}
+		if currentSection == "" {
+			expandedLines[idx] = line
+			continue
+		}
+
+		expanded, err := ReplacePlaceholders(line, func(name string) (string, error) {
+			value, ok := vars[name]
+			if !ok {
+				return "", &UnknownVariableError{Variable: name, Section: currentSection, Line: idx + 1}
+			}
+			return value, nil
+		})
+		var unknownErr *UnknownVariableError
+		if errors.As(err, &unknownErr) {
+			return brf, err
+		} else if err != nil {
+			return brf, fmt.Errorf("%w in section %s at line %d", err, currentSection, idx+1)
+		}
+		expandedLines[idx] = expanded
+	}
+
+	return CreateBrf(expandedLines), nil
+}
+
+// ReplacePlaceholders replaces all placeholders like ${date} in the given
+// line by the value that replace returns for the name of their variable.
+// Escaped placeholders ($${...}) are unescaped instead.
+//
+// The first error returned by replace is returned. If a placeholder
+// contains an invalid variable name an error is returned as well.
+func ReplacePlaceholders(line string, replace func(name string) (string, error)) (string, error) {
+	var replaceErr error
+	replaced := patternPlaceholder.ReplaceAllStringFunc(line, func(placeholder string) string {
+		if strings.HasPrefix(placeholder, "$$") {
+			return placeholder[1:]
+		}
+		if replaceErr != nil {
+			return placeholder
+		}
+
+		name := strings.TrimSpace(placeholder[2 : len(placeholder)-1])
+		if !patternVariableName.MatchString(name) {
+			replaceErr = fmt.Errorf("Invalid variable name %s", placeholder)
+			return placeholder
+		}
+
+		value, err := replace(name)
+		if err != nil {
+			replaceErr = err
+			return placeholder
+		}
+		return value
+	})
+	if replaceErr != nil {
+		return line, replaceErr
+	}
+	return replaced, nil
+}
@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExpandPlaceholders(t *testing.T) {
+	vars := map[string]string{
+		"date":    "2020-01-01",
+		"to.name": "Max Mustermann",
+	}
+
+	cases := []struct {
+		input  BrfLines
+		output BrfLines
+	}{
+		{BrfLines{".CONTENT", "Hello ${to.name}!"}, BrfLines{".CONTENT", "Hello Max Mustermann!"}},
+		{BrfLines{".DATE", "${date}", ".CONTENT", "${ date } and ${to.name}"}, BrfLines{".DATE", "2020-01-01", ".CONTENT", "2020-01-01 and Max Mustermann"}},
+		{BrfLines{".CONTENT", "literal $${date}"}, BrfLines{".CONTENT", "literal ${date}"}},
+		{BrfLines{"${unknown} outside of a section", ".CONTENT"}, BrfLines{"${unknown} outside of a section", ".CONTENT"}},
+	}
+	for _, tt := range cases {
+		brf, err := ExpandPlaceholders(CreateBrf(tt.input), vars)
+		if err != nil {
+			t.Errorf("ExpandPlaceholders(%q) returned error %v", tt.input, err)
+		} else if !reflect.DeepEqual(brf.Lines, tt.output) {
+			t.Errorf("ExpandPlaceholders(%q) == %q, expected %q", tt.input, brf.Lines, tt.output)
+		}
+	}
+}
+
+func TestExpandPlaceholdersUnknownVariable(t *testing.T) {
+	_, err := ExpandPlaceholders(CreateBrf(BrfLines{".SUBJECT", "Test", ".CONTENT", "", "Hello ${to.nmae}"}), map[string]string{})
+
+	var unknownErr *UnknownVariableError
+	if !errors.As(err, &unknownErr) {
+		t.Fatalf("Expected UnknownVariableError, got %v", err)
+	}
+	if unknownErr.Variable != "to.nmae" || unknownErr.Section != "CONTENT" || unknownErr.Line != 5 {
+		t.Errorf("Unexpected error content %+v", unknownErr)
+	}
+}
@@ -0,0 +1,206 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"poiu.de/brief/utils"
+)
+
+// The version of the inverted index file format. Index files with a
+// different version are discarded and rebuilt.
+const invertedIndexVersion = 1
+
+// indexedFile contains the tokens of a single indexed .brf file.
+type indexedFile struct {
+	// The modification time of the file when it was indexed.
+	ModTime time.Time `json:"modTime"`
+	// The distinct (lowercase) tokens in the file.
+	Tokens []string `json:"tokens"`
+}
+
+// InvertedIndex maps the tokens (words) of all .brf files to the files
+// containing them. It allows to skip files that cannot match a Query
+// without reading them.
+type InvertedIndex struct {
+	// The version of the index file format.
+	Version int `json:"version"`
+	// The indexed files with their absolute path as key.
+	Files map[string]indexedFile `json:"files"`
+
+	// The files containing a token with the token as key. Derived from
+	// Files.
+	tokens map[string]map[string]bool
+}
+
+// NewInvertedIndex creates a new empty InvertedIndex.
+func NewInvertedIndex() *InvertedIndex {
+	return &InvertedIndex{Version: invertedIndexVersion, Files: make(map[string]indexedFile)}
+}
+
+// LoadInvertedIndex reads an InvertedIndex from the given file.
+//
+// If the file does not exist or has an incompatible version, an empty
+// InvertedIndex is returned.
+func LoadInvertedIndex(indexFile string) (*InvertedIndex, error) {
+	content, err := os.ReadFile(indexFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewInvertedIndex(), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error reading search index %s: %w", indexFile, err)
+	}
+
+	idx := NewInvertedIndex()
+	err = json.Unmarshal(content, idx)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid search index %s: %w", indexFile, err)
+	}
+	if idx.Version != invertedIndexVersion || idx.Files == nil {
+		return NewInvertedIndex(), nil
+	}
+
+	return idx, nil
+}
+
+// Save writes this InvertedIndex to the given file.
+func (idx *InvertedIndex) Save(indexFile string) error {
+	err := os.MkdirAll(filepath.Dir(indexFile), 0755)
+	if err != nil {
+		return fmt.Errorf("Cannot create directory for search index %s: %w", indexFile, err)
+	}
+
+	content, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("Cannot serialize search index: %w", err)
+	}
+
+	err = utils.WriteFileAtomic(indexFile, content)
+	if err != nil {
+		return fmt.Errorf("Error writing search index %s: %w", indexFile, err)
+	}
+	return nil
+}
+
+// Update brings this InvertedIndex up to date with the .brf files in the
+// given document roots.
+//
+// Only files that were modified since they were last indexed are read
+// again. Files that don't exist anymore are removed from the index. Files
+// that cannot be read are logged and skipped.
+func (idx *InvertedIndex) Update(roots []string) error {
+	files, err := FindBrfFiles(roots)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]bool, len(files))
+	for _, file := range files {
+		found[file] = true
+
+		info, err := os.Stat(file)
+		if err != nil {
+			log.Printf("Skipping %s: %v", file, err)
+			delete(found, file)
+			continue
+		}
+		if indexed, ok := idx.Files[file]; ok && indexed.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			log.Printf("Skipping %s: %v", file, err)
+			delete(found, file)
+			continue
+		}
+		idx.Files[file] = indexedFile{ModTime: info.ModTime(), Tokens: Tokenize(string(content))}
+	}
+
+	for file := range idx.Files {
+		if !found[file] {
+			delete(idx.Files, file)
+		}
+	}
+
+	idx.tokens = nil
+	return nil
+}
+
+// Candidates returns the files that may match the given Query.
+//
+// These are the files that contain all tokens of all terms of the Query.
+// Tokens are matched as substrings of the indexed tokens, since terms are
+// matched as substrings as well.
+func (idx *InvertedIndex) Candidates(q Query) map[string]bool {
+	idx.buildTokenMap()
+
+	var candidates map[string]bool
+	for _, t := range q.Terms {
+		for _, queryToken := range Tokenize(t.Text) {
+			files := make(map[string]bool)
+			for token, tokenFiles := range idx.tokens {
+				if !strings.Contains(token, queryToken) {
+					continue
+				}
+				for f := range tokenFiles {
+					if candidates == nil || candidates[f] {
+						files[f] = true
+					}
+				}
+			}
+			candidates = files
+		}
+	}
+
+	if candidates == nil {
+		// no tokens in the query at all (e.g. only punctuation)
+		candidates = make(map[string]bool, len(idx.Files))
+		for f := range idx.Files {
+			candidates[f] = true
+		}
+	}
+
+	return candidates
+}
+
+// buildTokenMap derives the map from tokens to files from the indexed
+// files, if not done already.
+func (idx *InvertedIndex) buildTokenMap() {
+	if idx.tokens != nil {
+		return
+	}
+
+	idx.tokens = make(map[string]map[string]bool)
+	for file, indexed := range idx.Files {
+		for _, token := range indexed.Tokens {
+			if idx.tokens[token] == nil {
+				idx.tokens[token] = make(map[string]bool)
+			}
+			idx.tokens[token][file] = true
+		}
+	}
+}
+
+// Tokenize splits the given text into distinct lowercase tokens consisting
+// of letters and digits.
+func Tokenize(text string) []string {
+	seen := make(map[string]bool)
+	tokens := make([]string, 0)
+	for _, token := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,110 @@
+/*
+ * Package search implements a full-text search over .brf files.
+ */
+package search
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Term is a single part of a search Query.
+type Term struct {
+	// The text to search for. May contain whitespace if the term was given
+	// as a quoted phrase.
+	Text string
+	// The section the text must be found in, like "SUBJECT". Empty if the
+	// text may be found anywhere.
+	Section string
+}
+
+// Query is a parsed search query. A .brf file matches a Query if it matches
+// all of its Terms.
+type Query struct {
+	Terms []Term
+}
+
+// fieldSections maps the field names that can be used in a query to the
+// sections of a .brf file.
+// Field names not contained in this map are mapped to the section of the
+// same (uppercase) name.
+var fieldSections = map[string]string{
+	"tag": "TAGS",
+}
+
+// ParseQuery parses the given query string.
+//
+// A query consists of whitespace separated terms. A term may be enclosed in
+// double quotes to search for a phrase containing whitespace. A term may be
+// prefixed by the name of a field (section) followed by a colon to only
+// search inside that section, like
+//
+//	subject:tax to:"Finanzamt Berlin" 2024
+//
+// The search is case insensitive.
+func ParseQuery(s string) (Query, error) {
+	q := Query{Terms: make([]Term, 0)}
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		// skip whitespace
+		if runes[i] == ' ' || runes[i] == '\t' {
+			i++
+			continue
+		}
+
+		var t Term
+		start := i
+		// an optional field name
+		for i < len(runes) && runes[i] != ' ' && runes[i] != '\t' && runes[i] != ':' && runes[i] != '"' {
+			i++
+		}
+		if i < len(runes) && runes[i] == ':' && i > start {
+			field := strings.ToLower(string(runes[start:i]))
+			if section, ok := fieldSections[field]; ok {
+				t.Section = section
+			} else {
+				t.Section = strings.ToUpper(field)
+			}
+			i++
+		} else {
+			i = start
+		}
+
+		// the text itself
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return q, fmt.Errorf("Unbalanced quote at position %d in query %s", i+1, s)
+			}
+			t.Text = string(runes[i+1 : end])
+			i = end + 1
+		} else {
+			start = i
+			for i < len(runes) && runes[i] != ' ' && runes[i] != '\t' {
+				i++
+			}
+			t.Text = string(runes[start:i])
+		}
+
+		if strings.TrimSpace(t.Text) == "" {
+			return q, fmt.Errorf("Empty search term at position %d in query %s", start+1, s)
+		}
+		q.Terms = append(q.Terms, t)
+	}
+
+	if len(q.Terms) == 0 {
+		return q, fmt.Errorf("Empty query")
+	}
+
+	return q, nil
+}
+
+// IsPlain returns true if this Query consists of a single term without a
+// field. Such queries can also be handled by external search tools.
+func (q Query) IsPlain() bool {
+	return len(q.Terms) == 1 && q.Terms[0].Section == ""
+}
@@ -0,0 +1,170 @@
+package search
+
+import (
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"poiu.de/brief/parser"
+)
+
+// Match is a single line of a .brf file that matches a Query.
+type Match struct {
+	// The .brf file containing the line.
+	File string
+	// The line number (starting at 1).
+	Line int
+	// The content of the line.
+	Text string
+	// The byte ranges of the matched terms inside Text.
+	Ranges [][2]int
+}
+
+// Search searches all .brf files in the given document roots for the
+// given Query.
+//
+// If an InvertedIndex is given, it is used to skip files that cannot match
+// the Query. It must be up to date with the document roots.
+//
+// The returned Matches are sorted by file and line. Hidden directories
+// (starting with a dot) are skipped. .brf files that cannot be read are
+// logged and skipped.
+func Search(roots []string, q Query, idx *InvertedIndex) ([]Match, error) {
+	var candidates map[string]bool
+	if idx != nil {
+		candidates = idx.Candidates(q)
+	}
+
+	files, err := FindBrfFiles(roots)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]Match, 0)
+	for _, file := range files {
+		if candidates != nil && !candidates[file] {
+			continue
+		}
+
+		brf, err := parser.ReadBrfFile(file)
+		if err != nil {
+			log.Printf("Skipping %s: %v", file, err)
+			continue
+		}
+		matches = append(matches, SearchBrf(file, brf, q)...)
+	}
+
+	return matches, nil
+}
+
+// SearchBrf searches the given Brf (read from the given file) for the
+// given Query.
+//
+// If the Brf does not match all terms of the Query, no Matches are
+// returned. Otherwise all lines that contain at least one of the terms are
+// returned.
+func SearchBrf(file string, brf parser.Brf, q Query) []Match {
+	matches := make([]Match, 0)
+	found := make([]bool, len(q.Terms))
+
+	var currentSection string
+	for idx, line := range brf.Lines {
+		if s := parser.SectionId(line); s != "" {
+			currentSection = s
+			continue
+		}
+
+		ranges := make([][2]int, 0)
+		for i, t := range q.Terms {
+			if t.Section != "" && t.Section != currentSection || t.Text == "" {
+				continue
+			}
+			for offset := 0; ; {
+				start, end := indexFold(line[offset:], t.Text)
+				if start < 0 {
+					break
+				}
+				found[i] = true
+				ranges = append(ranges, [2]int{offset + start, offset + end})
+				offset += end
+			}
+		}
+
+		if len(ranges) > 0 {
+			sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+			matches = append(matches, Match{File: file, Line: idx + 1, Text: line, Ranges: ranges})
+		}
+	}
+
+	for _, f := range found {
+		if !f {
+			return nil
+		}
+	}
+
+	return matches
+}
+
+// indexFold returns the byte range of the first occurrence of term in s
+// compared case-insensitively, or -1, -1 if term is not found.
+//
+// The range refers to s itself, since the case-folded text may differ in
+// length (like for İ or the Kelvin sign).
+func indexFold(s, term string) (int, int) {
+	for start := range s {
+		end := start
+		for _, t := range term {
+			if end >= len(s) {
+				end = -1
+				break
+			}
+			r, size := utf8.DecodeRuneInString(s[end:])
+			if r != t && !strings.EqualFold(string(r), string(t)) {
+				end = -1
+				break
+			}
+			end += size
+		}
+		if end >= 0 {
+			return start, end
+		}
+	}
+	return -1, -1
+}
+
+// FindBrfFiles returns the absolute paths of all .brf files in the given
+// document roots, sorted by name. Hidden directories (starting with a dot)
+// are skipped.
+func FindBrfFiles(roots []string) ([]string, error) {
+	files := make([]string, 0)
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(path) == ".brf" {
+				absPath, err := filepath.Abs(path)
+				if err != nil {
+					return err
+				}
+				files = append(files, absPath)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
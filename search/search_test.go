@@ -0,0 +1,118 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"poiu.de/brief/parser"
+)
+
+func TestParseQuery(t *testing.T) {
+	cases := []struct {
+		input string
+		terms []Term
+	}{
+		{"tax", []Term{{Text: "tax"}}},
+		{"  tax  2024 ", []Term{{Text: "tax"}, {Text: "2024"}}},
+		{`"Finanzamt Berlin"`, []Term{{Text: "Finanzamt Berlin"}}},
+		{`subject:tax to:"Finanzamt Berlin"`, []Term{{Text: "tax", Section: "SUBJECT"}, {Text: "Finanzamt Berlin", Section: "TO"}}},
+		{"tag:rent", []Term{{Text: "rent", Section: "TAGS"}}},
+	}
+	for _, tt := range cases {
+		q, err := ParseQuery(tt.input)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) returned error %v", tt.input, err)
+		} else if !reflect.DeepEqual(q.Terms, tt.terms) {
+			t.Errorf("ParseQuery(%q) == %+v, expected %+v", tt.input, q.Terms, tt.terms)
+		}
+	}
+
+	for _, invalid := range []string{"", `"unbalanced`, "subject:"} {
+		if _, err := ParseQuery(invalid); err == nil {
+			t.Errorf("ParseQuery(%q) should return an error", invalid)
+		}
+	}
+}
+
+func TestSearchBrf(t *testing.T) {
+	brf := parser.CreateBrf(parser.BrfLines{".TO", "Finanzamt Berlin", ".SUBJECT", "Steuererklärung 2024", ".CONTENT", "Anbei die Steuererklärung.", "Mit freundlichen Grüßen"})
+
+	cases := []struct {
+		query string
+		lines []int
+	}{
+		{"steuer", []int{4, 6}},
+		{"subject:steuer", []int{4}},
+		{`to:"amt berlin" grüßen`, []int{2, 7}},
+		{"subject:steuer to:köln", []int{}},
+		{"content:berlin", []int{}},
+	}
+	for _, tt := range cases {
+		q, _ := ParseQuery(tt.query)
+		lines := make([]int, 0)
+		for _, m := range SearchBrf("test.brf", brf, q) {
+			lines = append(lines, m.Line)
+		}
+		if !reflect.DeepEqual(lines, tt.lines) {
+			t.Errorf("SearchBrf(%q) matched lines %v, expected %v", tt.query, lines, tt.lines)
+		}
+	}
+}
+
+func TestSearchBrfRanges(t *testing.T) {
+	cases := []struct {
+		line   string
+		query  string
+		ranges [][2]int
+	}{
+		{"Steuer und STEUER", "steuer", [][2]int{{0, 6}, {11, 17}}},
+		{"İstanbul, İzmir", "izmir", [][2]int{}},
+		{"İstanbul, İzmir", "İzmir", [][2]int{{11, 17}}},
+		{"20 \u212a warm, 20 k kalt", "k", [][2]int{{3, 6}, {16, 17}, {18, 19}}},
+		{"Grüße aus GRÜNAU", "grü", [][2]int{{0, 4}, {12, 16}}},
+	}
+	for _, tt := range cases {
+		brf := parser.CreateBrf(parser.BrfLines{".CONTENT", tt.line})
+		q, _ := ParseQuery(tt.query)
+		ranges := make([][2]int, 0)
+		for _, m := range SearchBrf("test.brf", brf, q) {
+			ranges = append(ranges, m.Ranges...)
+			for _, r := range m.Ranges {
+				if !strings.EqualFold(m.Text[r[0]:r[1]], tt.query) {
+					t.Errorf("SearchBrf(%q) matched %q in %q", tt.query, m.Text[r[0]:r[1]], m.Text)
+				}
+			}
+		}
+		if !reflect.DeepEqual(ranges, tt.ranges) {
+			t.Errorf("SearchBrf(%q) in %q matched ranges %v, expected %v", tt.query, tt.line, ranges, tt.ranges)
+		}
+	}
+}
+
+func TestSearchSkipsUnreadableFiles(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.brf"), []byte(".SUBJECT\nSteuer\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "missing"), filepath.Join(root, "broken.brf")); err != nil {
+		t.Fatal(err)
+	}
+	q, _ := ParseQuery("steuer")
+
+	idx := NewInvertedIndex()
+	if err := idx.Update([]string{root}); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	for _, i := range []*InvertedIndex{nil, idx} {
+		matches, err := Search([]string{root}, q, i)
+		if err != nil {
+			t.Fatalf("Search() failed: %v", err)
+		}
+		if len(matches) != 1 || filepath.Base(matches[0].File) != "a.brf" {
+			t.Errorf("Search() == %+v, expected a match in a.brf", matches)
+		}
+	}
+}
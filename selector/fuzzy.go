@@ -0,0 +1,71 @@
+package selector
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// FuzzyMatch checks whether all characters of the given pattern appear in
+// the given text in the same order (case insensitive).
+//
+// If they do, a score is returned that is higher the better the pattern
+// matches. Consecutive characters and characters at the start of words are
+// scored higher. An empty pattern matches every text with a score of 0.
+func FuzzyMatch(pattern, text string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(strings.ToLower(text))
+
+	score := 0
+	pi := 0
+	lastMatch := -2
+	for ti := 0; ti < len(t) && pi < len(p); ti++ {
+		if t[ti] != p[pi] {
+			continue
+		}
+
+		score++
+		if lastMatch == ti-1 {
+			// consecutive characters
+			score += 5
+		}
+		if ti == 0 || !unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1]) {
+			// start of a word
+			score += 3
+		}
+		lastMatch = ti
+		pi++
+	}
+
+	if pi < len(p) {
+		return 0, false
+	}
+	return score, true
+}
+
+// Filter returns the indices of all given Items whose label matches the
+// given pattern, sorted by their score (best first). Items with the same
+// score keep their original order.
+func Filter(items []Item, pattern string) []int {
+	type scored struct {
+		index int
+		score int
+	}
+
+	matches := make([]scored, 0, len(items))
+	for i, item := range items {
+		if score, ok := FuzzyMatch(pattern, item.Label); ok {
+			matches = append(matches, scored{i, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	indices := make([]int, len(matches))
+	for i, m := range matches {
+		indices[i] = m.index
+	}
+	return indices
+}
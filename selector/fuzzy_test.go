@@ -0,0 +1,78 @@
+package selector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		text    string
+		matches bool
+	}{
+		{"", "anything", true},
+		{"fa", "Finanzamt", true},
+		{"FNZ", "finanzamt", true},
+		{"zf", "finanzamt", false},
+		{"steuer", "2024-01-01 Finanzamt Steuer", true},
+		{"xyz", "Finanzamt", false},
+	}
+
+	for _, c := range cases {
+		_, ok := FuzzyMatch(c.pattern, c.text)
+		if ok != c.matches {
+			t.Errorf("FuzzyMatch(%q, %q) == %v, expected %v", c.pattern, c.text, ok, c.matches)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	items := []Item{
+		{Label: "afabcx"},
+		{Label: "Finanzamt Berlin"},
+		{Label: "Krankenkasse"},
+		{Label: "fa"},
+	}
+
+	cases := []struct {
+		pattern  string
+		expected []int
+	}{
+		{"", []int{0, 1, 2, 3}},
+		{"fa", []int{3, 0, 1}},
+		{"kk", []int{2}},
+		{"q", []int{}},
+	}
+
+	for _, c := range cases {
+		result := Filter(items, c.pattern)
+		if !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("Filter(items, %q) == %v, expected %v", c.pattern, result, c.expected)
+		}
+	}
+}
+
+func TestSelectNumbered(t *testing.T) {
+	items := []Item{{Label: "a", Value: "1"}, {Label: "b", Value: "2"}}
+
+	cases := []struct {
+		input    string
+		expected string
+		err      error
+	}{
+		{"2\n", "2", nil},
+		{"x\n1\n", "1", nil},
+		{"3\n\n", "", ErrCancelled},
+		{"", "", ErrCancelled},
+	}
+
+	for _, c := range cases {
+		out := &strings.Builder{}
+		item, err := selectNumbered(items, "Select", strings.NewReader(c.input), out)
+		if item.Value != c.expected || err != c.err {
+			t.Errorf("selectNumbered(%q) == (%q, %v), expected (%q, %v)", c.input, item.Value, err, c.expected, c.err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * Package selector lets the user select one of several items, either via
+ * an external lister (like fzf), a built-in fuzzy selector on the terminal
+ * or a numbered prompt.
+ */
+package selector
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"poiu.de/brief/cmdline"
+)
+
+// ErrCancelled is returned if the user cancelled the selection.
+var ErrCancelled = errors.New("Selection cancelled")
+
+// Item is a single selectable entry.
+type Item struct {
+	// The text shown in the list and used for filtering.
+	Label string
+	// The value of this Item, like a file path. Not shown to the user.
+	Value string
+	// Additional lines shown in the preview pane when this Item is the
+	// current one.
+	Preview []string
+}
+
+// Select lets the user select one of the given Items.
+//
+// If listerCommand is not empty and the lister executable is available,
+// it is used for the selection. The labels of the items are written to
+// its stdin (one per line) and the selected label is read from its stdout.
+//
+// Otherwise the built-in fuzzy selector is used if stdin is a terminal,
+// and a numbered prompt if not.
+//
+// If the user cancels the selection, ErrCancelled is returned.
+func Select(items []Item, prompt string, listerCommand string) (Item, error) {
+	if len(items) == 0 {
+		return Item{}, errors.New("Nothing to select")
+	}
+
+	if isAvailable(listerCommand) {
+		return selectExternal(items, listerCommand)
+	}
+
+	if isTerminal(os.Stdin) {
+		if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+			defer tty.Close()
+			return selectFuzzy(items, prompt, tty)
+		}
+	}
+
+	return selectNumbered(items, prompt, os.Stdin, os.Stderr)
+}
+
+// isAvailable returns true if the executable of the given command line can
+// be found.
+func isAvailable(cmdLine string) bool {
+	pipelines, err := cmdline.NewParser().Parse(cmdLine)
+	if err != nil || len(pipelines) == 0 {
+		return false
+	}
+	_, err = exec.LookPath(pipelines[0][0].CmdName)
+	return err == nil
+}
+
+// selectExternal lets the user select one of the given Items via the given
+// external lister command.
+func selectExternal(items []Item, listerCommand string) (Item, error) {
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = item.Label
+	}
+
+	stdin := strings.NewReader(strings.Join(labels, "\n") + "\n")
+	stdout := &strings.Builder{}
+	err := cmdline.Execute(listerCommand, "", stdin, stdout, os.Stderr)
+	selected := strings.TrimRight(stdout.String(), "\r\n")
+	if err != nil || selected == "" {
+		// listers like fzf exit with an error if nothing was selected
+		return Item{}, ErrCancelled
+	}
+
+	for _, item := range items {
+		if item.Label == selected {
+			return item, nil
+		}
+	}
+	return Item{}, fmt.Errorf("Unknown selection %s", selected)
+}
+
+// selectNumbered lets the user select one of the given Items by entering
+// its number. The Items are written to out and the answer is read from in.
+func selectNumbered(items []Item, prompt string, in io.Reader, out io.Writer) (Item, error) {
+	for i, item := range items {
+		fmt.Fprintf(out, "%3d) %s\n", i+1, item.Label)
+	}
+
+	for {
+		fmt.Fprintf(out, "%s [1-%d]: ", prompt, len(items))
+		line, err := readLine(in)
+		if err != nil && line == "" {
+			return Item{}, ErrCancelled
+		}
+
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			return Item{}, ErrCancelled
+		}
+		n, err := strconv.Atoi(answer)
+		if err == nil && n >= 1 && n <= len(items) {
+			return items[n-1], nil
+		}
+		fmt.Fprintf(out, "Invalid selection %s\n", answer)
+	}
+}
+
+// readLine reads a single line from the given reader.
+//
+// The reader is read byte by byte so that no input after the line is
+// consumed. This allows several prompts to read from the same stdin.
+func readLine(in io.Reader) (string, error) {
+	var b strings.Builder
+	buf := make([]byte, 1)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				return b.String(), nil
+			}
+			b.WriteByte(buf[0])
+		}
+		if err != nil {
+			return b.String(), err
+		}
+	}
+}
+
+// isTerminal returns true if the given file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,189 @@
+package selector
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The number of lines reserved for the preview pane.
+const previewHeight = 4
+
+// selectFuzzy lets the user select one of the given Items via a built-in
+// fuzzy selector on the given terminal.
+//
+// The user can type to filter the Items, move the cursor with the arrow
+// keys (or Ctrl-P / Ctrl-N), select the current Item with Enter and cancel
+// with Escape or Ctrl-C. The preview pane below the list shows the preview
+// of the current Item.
+func selectFuzzy(items []Item, prompt string, tty *os.File) (Item, error) {
+	restore, err := makeRaw(tty)
+	if err != nil {
+		return selectNumbered(items, prompt, tty, tty)
+	}
+	defer restore()
+
+	rows, cols := terminalSize(tty)
+	listHeight := rows - previewHeight - 2
+	if listHeight < 1 {
+		listHeight = 1
+	}
+
+	pattern := []rune{}
+	matches := Filter(items, "")
+	cursor := 0
+	buf := make([]byte, 64)
+
+	for {
+		render(tty, items, matches, cursor, prompt, string(pattern), listHeight, cols)
+
+		n, err := tty.Read(buf)
+		if err != nil {
+			clearScreen(tty)
+			return Item{}, err
+		}
+		input := buf[:n]
+
+		switch {
+		case string(input) == "\r" || string(input) == "\n":
+			clearScreen(tty)
+			if len(matches) == 0 {
+				return Item{}, ErrCancelled
+			}
+			return items[matches[cursor]], nil
+		case string(input) == "\x1b" || string(input) == "\x03" || string(input) == "\x07":
+			// Escape, Ctrl-C, Ctrl-G
+			clearScreen(tty)
+			return Item{}, ErrCancelled
+		case string(input) == "\x1b[A" || string(input) == "\x1bOA" || string(input) == "\x10":
+			// Up, Ctrl-P
+			if cursor > 0 {
+				cursor--
+			}
+		case string(input) == "\x1b[B" || string(input) == "\x1bOB" || string(input) == "\x0e":
+			// Down, Ctrl-N
+			if cursor < len(matches)-1 {
+				cursor++
+			}
+		case string(input) == "\x7f" || string(input) == "\x08":
+			// Backspace
+			if len(pattern) > 0 {
+				pattern = pattern[:len(pattern)-1]
+				matches = Filter(items, string(pattern))
+				cursor = 0
+			}
+		case string(input) == "\x15":
+			// Ctrl-U
+			pattern = pattern[:0]
+			matches = Filter(items, "")
+			cursor = 0
+		case input[0] >= 0x20 && input[0] != 0x7f && utf8.Valid(input):
+			pattern = append(pattern, []rune(string(input))...)
+			matches = Filter(items, string(pattern))
+			cursor = 0
+		}
+	}
+}
+
+// render draws the prompt, the filtered list and the preview pane onto the
+// given terminal.
+func render(tty *os.File, items []Item, matches []int, cursor int, prompt, pattern string, listHeight, cols int) {
+	var b strings.Builder
+	// move to the top left corner and clear the screen
+	b.WriteString("\x1b[H\x1b[2J")
+
+	fmt.Fprintf(&b, "%s> %s\r\n", prompt, pattern)
+
+	// scroll the list so that the cursor is always visible
+	first := 0
+	if cursor >= listHeight {
+		first = cursor - listHeight + 1
+	}
+	for i := first; i < len(matches) && i < first+listHeight; i++ {
+		label := truncate(items[matches[i]].Label, cols-2)
+		if i == cursor {
+			fmt.Fprintf(&b, "\x1b[7m> %s\x1b[0m\r\n", label)
+		} else {
+			fmt.Fprintf(&b, "  %s\r\n", label)
+		}
+	}
+	for i := len(matches) - first; i < listHeight; i++ {
+		b.WriteString("\r\n")
+	}
+
+	fmt.Fprintf(&b, "%s\r\n", strings.Repeat("─", cols))
+	if len(matches) > 0 {
+		preview := items[matches[cursor]].Preview
+		for i := 0; i < len(preview) && i < previewHeight; i++ {
+			fmt.Fprintf(&b, "%s\r\n", truncate(preview[i], cols))
+		}
+	}
+
+	tty.WriteString(b.String())
+}
+
+// clearScreen clears the given terminal.
+func clearScreen(tty *os.File) {
+	tty.WriteString("\x1b[H\x1b[2J")
+}
+
+// truncate shortens the given string to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if n < 0 {
+		n = 0
+	}
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// makeRaw puts the given terminal into raw mode via stty.
+//
+// The returned function restores the previous mode of the terminal.
+func makeRaw(tty *os.File) (func(), error) {
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = stty(tty, "raw", "-echo")
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		stty(tty, strings.TrimSpace(saved))
+	}, nil
+}
+
+// terminalSize returns the number of rows and columns of the given
+// terminal. If they cannot be determined, 24 rows and 80 columns are
+// returned.
+func terminalSize(tty *os.File) (int, int) {
+	size, err := stty(tty, "size")
+	if err == nil {
+		fields := strings.Fields(size)
+		if len(fields) == 2 {
+			rows, err1 := strconv.Atoi(fields[0])
+			cols, err2 := strconv.Atoi(fields[1])
+			if err1 == nil && err2 == nil && rows > 0 && cols > 0 {
+				return rows, cols
+			}
+		}
+	}
+	return 24, 80
+}
+
+// stty calls the stty command with the given arguments on the given
+// terminal and returns its output.
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// AtomicFile is a file that replaces its destination only after it was
+// written completely.
+//
+// It is written to a temporary file in the directory of the destination,
+// which is renamed to the destination on Commit. Until then the destination
+// keeps its previous content (or doesn't exist), so that no truncated file
+// is left behind if writing fails.
+type AtomicFile struct {
+	*os.File
+	// The path of the destination file.
+	dest string
+	// Whether the file was already committed or aborted.
+	done bool
+}
+
+// CreateAtomic creates an AtomicFile for the given destination file.
+func CreateAtomic(dest string) (*AtomicFile, error) {
+	tmpFile, err := TempFileFor(dest)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(tmpFile, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		os.Remove(tmpFile)
+		return nil, fmt.Errorf("Cannot open temporary file for %s: %w", dest, err)
+	}
+	return &AtomicFile{File: f, dest: dest}, nil
+}
+
+// Commit closes the AtomicFile and replaces its destination with it.
+func (f *AtomicFile) Commit() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+
+	err := f.File.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("Cannot write %s: %w", f.dest, err)
+	}
+	return CommitTempFile(f.Name(), f.dest)
+}
+
+// Abort closes and removes the AtomicFile without touching its destination.
+//
+// Calling Abort after Commit has no effect, so that it can be deferred
+// right after creating the AtomicFile.
+func (f *AtomicFile) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+
+	f.File.Close()
+	os.Remove(f.Name())
+}
+
+// WriteFileAtomic writes the given data to the given file like
+// os.WriteFile, but replaces the file only after all data was written.
+func WriteFileAtomic(file string, data []byte) error {
+	f, err := CreateAtomic(file)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+
+	_, err = f.Write(data)
+	if err != nil {
+		return fmt.Errorf("Cannot write %s: %w", file, err)
+	}
+	return f.Commit()
+}
+
+// TempFilePrefix is the prefix of the names of temporary files created by
+// TempFileFor.
+const TempFilePrefix = ".brief-tmp-"
+
+// TempFileFor creates an empty temporary file in the directory of the
+// given destination file and returns its path.
+//
+// The name of the temporary file starts with TempFilePrefix and it has the
+// same extension as the destination, so that external applications writing
+// to it recognize the file type. Once written completely, it replaces the
+// destination via CommitTempFile.
+func TempFileFor(dest string) (string, error) {
+	base := filepath.Base(dest)
+	ext := filepath.Ext(base)
+	f, err := os.CreateTemp(filepath.Dir(dest), TempFilePrefix+base[:len(base)-len(ext)]+".*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("Cannot create temporary file for %s: %w", dest, err)
+	}
+	f.Close()
+	return f.Name(), nil
+}
+
+// CommitTempFile replaces the given destination file with the given
+// temporary file (see TempFileFor).
+//
+// The temporary file is removed if it cannot be renamed.
+func CommitTempFile(tmpFile string, dest string) error {
+	err := os.Chmod(tmpFile, 0644)
+	if err == nil {
+		err = os.Rename(tmpFile, dest)
+	}
+	if err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("Cannot replace %s: %w", dest, err)
+	}
+	return nil
+}
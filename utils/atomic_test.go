@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAtomicFile(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "letter.tex")
+	if err := os.WriteFile(dest, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// an aborted file leaves the destination untouched
+	f, err := CreateAtomic(dest)
+	if err != nil {
+		t.Fatalf("CreateAtomic() failed: %v", err)
+	}
+	f.WriteString("partial")
+	f.Abort()
+	if content, _ := os.ReadFile(dest); string(content) != "old" {
+		t.Errorf("Destination after Abort() == %q, expected %q", content, "old")
+	}
+
+	f, err = CreateAtomic(dest)
+	if err != nil {
+		t.Fatalf("CreateAtomic() failed: %v", err)
+	}
+	f.WriteString("new")
+	if content, _ := os.ReadFile(dest); string(content) != "old" {
+		t.Errorf("Destination before Commit() == %q, expected %q", content, "old")
+	}
+	if err := f.Commit(); err != nil {
+		t.Fatalf("Commit() failed: %v", err)
+	}
+	f.Abort()
+	if content, _ := os.ReadFile(dest); string(content) != "new" {
+		t.Errorf("Destination after Commit() == %q, expected %q", content, "new")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Directory contains %d files, expected no temporary files", len(entries))
+	}
+}
+
+func TestTempFileFor(t *testing.T) {
+	dir := t.TempDir()
+	tmpFile, err := TempFileFor(filepath.Join(dir, "letter.pdf"))
+	if err != nil {
+		t.Fatalf("TempFileFor() failed: %v", err)
+	}
+	if filepath.Dir(tmpFile) != dir || filepath.Ext(tmpFile) != ".pdf" || !strings.HasPrefix(filepath.Base(tmpFile), TempFilePrefix+"letter.") {
+		t.Errorf("TempFileFor() == %s, expected a .pdf file starting with %s in %s", tmpFile, TempFilePrefix, dir)
+	}
+}
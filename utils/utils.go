@@ -3,6 +3,7 @@ package utils
 import (
 	"path"
 	"strings"
+	"unicode"
 )
 
 // DeriveFilePath creates a new path for the given path by exchanging the
@@ -16,3 +17,56 @@ func DeriveFilePath(filePath, newExt string) string {
 	}
 	return path.Join(dir, basename+"."+newExt)
 }
+
+// SanitizeFileName converts the given string into a string that can be
+// safely used as (part of) a file name.
+//
+// Letters, digits, dots, dashes and underscores are kept as is. Whitespace
+// and all other characters are replaced by underscores. Multiple
+// consecutive underscores are collapsed into one and leading and trailing
+// underscores and dots are removed.
+func SanitizeFileName(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	lastUnderscore := false
+	for _, c := range s {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || c == '.' || c == '-' {
+			b.WriteRune(c)
+			lastUnderscore = false
+		} else if !lastUnderscore {
+			b.WriteRune('_')
+			lastUnderscore = true
+		}
+	}
+
+	return strings.Trim(b.String(), "_.")
+}
+
+// WrapText wraps the given text into lines of at most width characters.
+//
+// Lines are only broken at whitespace. Words longer than width are put on
+// a line of their own. Existing line breaks are replaced by spaces.
+func WrapText(text string, width int) []string {
+	lines := make([]string, 0)
+	var line strings.Builder
+	lineLen := 0
+	for _, word := range strings.Fields(text) {
+		wordLen := len([]rune(word))
+		if lineLen > 0 && lineLen+1+wordLen > width {
+			lines = append(lines, line.String())
+			line.Reset()
+			lineLen = 0
+		}
+		if lineLen > 0 {
+			line.WriteRune(' ')
+			lineLen++
+		}
+		line.WriteString(word)
+		lineLen += wordLen
+	}
+	if lineLen > 0 {
+		lines = append(lines, line.String())
+	}
+
+	return lines
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -21,3 +22,43 @@ func TestDeriveFilePath(t *testing.T) {
 		}
 	}
 }
+
+func TestSanitizeFileName(t *testing.T) {
+	cases := []struct {
+		input  string
+		output string
+	}{
+		{"Max Mustermann", "Max_Mustermann"},
+		{"Müller & Söhne GmbH", "Müller_Söhne_GmbH"},
+		{"../etc/passwd", "etc_passwd"},
+		{"2020-01-01", "2020-01-01"},
+		{"  a__b  ", "a_b"},
+	}
+	for _, tt := range cases {
+		sanitized := SanitizeFileName(tt.input)
+		if sanitized != tt.output {
+			t.Errorf("SanitizeFileName(%q) == %q, expected %q", tt.input, sanitized, tt.output)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	cases := []struct {
+		input  string
+		width  int
+		output []string
+	}{
+		{"", 10, []string{}},
+		{"a b c", 10, []string{"a b c"}},
+		{"aaa bbb ccc", 7, []string{"aaa bbb", "ccc"}},
+		{"aaa\nbbb  ccc", 3, []string{"aaa", "bbb", "ccc"}},
+		{"a verylongword b", 5, []string{"a", "verylongword", "b"}},
+		{"äöü äöü", 7, []string{"äöü äöü"}},
+	}
+	for _, tt := range cases {
+		wrapped := WrapText(tt.input, tt.width)
+		if !reflect.DeepEqual(wrapped, tt.output) {
+			t.Errorf("WrapText(%q, %d) == %q, expected %q", tt.input, tt.width, wrapped, tt.output)
+		}
+	}
+}